@@ -0,0 +1,90 @@
+package echozapmiddleware
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// AccessLogFormat defines the message format of the log entries.
+type AccessLogFormat string
+
+const (
+	// AccessLogFormatDefault uses a short message describing the status class, e.g. "Success".
+	AccessLogFormatDefault AccessLogFormat = ""
+	// AccessLogFormatCombined uses the Apache/Nginx combined log format line as the message.
+	AccessLogFormatCombined AccessLogFormat = "combined"
+)
+
+const combinedLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// logMessage returns the message of the log entry.
+func logMessage(config ZapConfig, c echo.Context, start time.Time, slow bool) string {
+	status := c.Response().Status
+
+	if config.MessageFunc != nil {
+		if message := config.MessageFunc(c, status); message != "" {
+			return message
+		}
+	}
+
+	if config.AccessLogFormat == AccessLogFormatCombined {
+		return combinedLogLine(config, c, start)
+	}
+
+	if message, ok := config.Messages[status]; ok {
+		return message
+	}
+
+	if message, ok := config.ClassMessages[strconv.Itoa(status/100)+"xx"]; ok {
+		return message
+	}
+
+	return defaultMessage(status, slow)
+}
+
+// combinedLogLine formats the request like `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`.
+func combinedLogLine(config ZapConfig, c echo.Context, start time.Time) string {
+	req := c.Request()
+	res := c.Response()
+
+	user, _, ok := req.BasicAuth()
+	if !ok || user == "" {
+		user = "-"
+	}
+
+	size := "-"
+	if res.Size > 0 {
+		size = strconv.FormatInt(res.Size, 10)
+	}
+
+	var b strings.Builder
+
+	b.WriteString(c.RealIP())
+	b.WriteString(" - ")
+	b.WriteString(user)
+	b.WriteString(" [")
+	b.WriteString(start.Format(combinedLogTimeFormat))
+	b.WriteString("] ")
+	b.WriteString(strconv.Quote(req.Method + " " + requestURI(config, c) + " " + req.Proto))
+	b.WriteString(" ")
+	b.WriteString(strconv.Itoa(res.Status))
+	b.WriteString(" ")
+	b.WriteString(size)
+	b.WriteString(" ")
+	b.WriteString(combinedLogValue(req.Referer()))
+	b.WriteString(" ")
+	b.WriteString(combinedLogValue(req.UserAgent()))
+
+	return b.String()
+}
+
+func combinedLogValue(value string) string {
+	if value == "" {
+		return `"-"`
+	}
+
+	return strconv.Quote(value)
+}
@@ -0,0 +1,343 @@
+package echozapmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultArchivePattern = "echozap-*.body"
+	// maxQueuedArchiveBytes is the number of bytes of a body waiting to be archived.
+	// The archived copy is truncated if its writes fall further behind.
+	maxQueuedArchiveBytes = 4 << 20
+	// archiveQueueSize is the number of chunks of a body waiting to be archived.
+	archiveQueueSize = 256
+)
+
+// BodyArchiver stores bodies exceeding LimitSize, so full payloads stay available while logs keep them truncated.
+//
+// Archived bodies are stored unredacted: RedactJSONFields, RedactFormFields, RedactXMLElements
+// and BodyRedactors apply only to the logged copy. Only bodies of ArchiveContentTypes are archived.
+type BodyArchiver interface {
+	// Create starts storing a body and returns a writer the body is streamed to
+	// and a reference to the stored body, e.g. a file path or an object ID.
+	// It's called on the request path, while the writer is written to and closed in a background goroutine
+	Create(ctx context.Context) (io.WriteCloser, string, error)
+	// Remove deletes a stored body which turned out to be excluded from logging.
+	Remove(ctx context.Context, ref string) error
+}
+
+// TempFileArchiver is a BodyArchiver which writes every body into a new file.
+// Files are kept until they are removed, e.g. by calling Cleanup periodically.
+type TempFileArchiver struct {
+	// Dir is the directory files are created in. If empty, os.TempDir() is used
+	Dir string
+	// Pattern is the file name pattern as in os.CreateTemp. If empty, "echozap-*.body" is used
+	Pattern string
+}
+
+// Create creates a new file and returns it with its path.
+func (a TempFileArchiver) Create(context.Context) (io.WriteCloser, string, error) {
+	pattern := a.Pattern
+	if pattern == "" {
+		pattern = defaultArchivePattern
+	}
+
+	file, err := os.CreateTemp(a.Dir, pattern)
+	if err != nil {
+		return nil, "", fmt.Errorf("error creating archive file: %w", err)
+	}
+
+	return file, file.Name(), nil
+}
+
+// Cleanup removes files matching Pattern in Dir which were modified more than maxAge ago.
+func (a TempFileArchiver) Cleanup(maxAge time.Duration) error {
+	dir := a.Dir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	pattern := a.Pattern
+	if pattern == "" {
+		pattern = defaultArchivePattern
+	}
+
+	if !strings.Contains(pattern, "*") {
+		pattern += "*"
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return fmt.Errorf("error listing archive files: %w", err)
+	}
+
+	deadline := time.Now().Add(-maxAge)
+
+	var errs []error
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() || info.ModTime().After(deadline) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("error removing archive files: %w", err)
+	}
+
+	return nil
+}
+
+// Remove removes the file at path ref.
+func (a TempFileArchiver) Remove(_ context.Context, ref string) error {
+	if err := os.Remove(ref); err != nil {
+		return fmt.Errorf("error removing archive file: %w", err)
+	}
+
+	return nil
+}
+
+// archivedBody is a reference to an archived body.
+type archivedBody struct {
+	ref string
+	// size is the number of body bytes read or written, so it's the archived size unless truncated
+	size int64
+	// hash is the SHA-256 of the archived copy
+	hash      string
+	truncated bool
+	err       error
+}
+
+func (b archivedBody) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if b.err != nil {
+		enc.AddString("error", b.err.Error())
+	} else {
+		enc.AddString("ref", b.ref)
+		enc.AddString("sha256", b.hash)
+	}
+
+	enc.AddInt64("size", b.size)
+
+	if b.truncated {
+		enc.AddBool("truncated", true)
+	}
+
+	return nil
+}
+
+// archiveSink tees a body to a BodyArchiver. The first LimitSize bytes are held back,
+// so only bodies exceeding them are archived. The archive is written in a background goroutine,
+// and the archived copy is truncated rather than blocking the request when its writes fall behind.
+type archiveSink struct {
+	archiver     BodyArchiver
+	contentTypes []string
+	// ctx isn't canceled with the request, as the archive is written after it
+	ctx   context.Context
+	limit int
+
+	pending []byte
+	size    int64
+	hash    hash.Hash
+	ref     string
+	err     error
+
+	queue  chan []byte
+	queued atomic.Int64
+	// truncated is set once a chunk is dropped, so the archived copy ends before the body does
+	truncated bool
+	// complete is set once the whole body has passed through the sink
+	complete bool
+	// removed is set before the queue is closed, if the archived copy has to be removed
+	removed  bool
+	finished bool
+}
+
+// newArchiveSink returns a sink archiving a body if it exceeds LimitSize, or nil if bodies aren't archived.
+func newArchiveSink(ctx context.Context, config ZapConfig) *archiveSink {
+	if config.BodyArchiver == nil || !config.LimitHTTPBody {
+		return nil
+	}
+
+	return &archiveSink{
+		archiver:     config.BodyArchiver,
+		contentTypes: config.ArchiveContentTypes,
+		ctx:          context.WithoutCancel(ctx),
+		limit:        config.LimitSize,
+	}
+}
+
+// forMediaType returns the sink, or nil if bodies of mediaType aren't archived.
+func (s *archiveSink) forMediaType(mediaType string) *archiveSink {
+	if s == nil || !matchMediaType(s.contentTypes, mediaType) {
+		return nil
+	}
+
+	return s
+}
+
+// write tees b to the archive. b isn't retained.
+func (s *archiveSink) write(b []byte) {
+	if s == nil || s.finished || len(b) == 0 {
+		return
+	}
+
+	s.size += int64(len(b))
+
+	if s.err != nil {
+		return
+	}
+
+	if s.queue == nil {
+		if len(s.pending)+len(b) <= s.limit {
+			s.pending = append(s.pending, b...)
+			return
+		}
+
+		if !s.start() {
+			return
+		}
+	}
+
+	s.send(b)
+}
+
+// start creates the archive, starts writing it and queues the held back bytes.
+func (s *archiveSink) start() bool {
+	w, ref, err := s.archiver.Create(s.ctx)
+	if err != nil {
+		s.err = err
+		s.pending = nil
+
+		return false
+	}
+
+	s.ref = ref
+	s.hash = sha256.New()
+	s.queue = make(chan []byte, archiveQueueSize)
+
+	go s.run(w)
+
+	s.send(s.pending)
+	s.pending = nil
+
+	return true
+}
+
+// send queues a copy of b, or truncates the archived copy if the queue is full.
+func (s *archiveSink) send(b []byte) {
+	if s.truncated || len(b) == 0 {
+		return
+	}
+
+	if s.queued.Load()+int64(len(b)) > maxQueuedArchiveBytes {
+		s.truncated = true
+		return
+	}
+
+	chunk := append([]byte(nil), b...)
+	s.queued.Add(int64(len(chunk)))
+
+	select {
+	case s.queue <- chunk:
+		s.hash.Write(chunk)
+	default:
+		s.queued.Add(-int64(len(chunk)))
+		s.truncated = true
+	}
+}
+
+// run writes the queued chunks to w and closes it.
+func (s *archiveSink) run(w io.WriteCloser) {
+	var err error
+
+	for chunk := range s.queue {
+		if err == nil {
+			_, err = w.Write(chunk)
+		}
+
+		s.queued.Add(-int64(len(chunk)))
+	}
+
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+
+	if s.removed {
+		err = s.archiver.Remove(s.ctx, s.ref)
+	}
+
+	if err != nil {
+		stats.Add(statsArchiveErrors, 1)
+	}
+}
+
+// end marks the whole body as passed through the sink.
+func (s *archiveSink) end() {
+	if s != nil {
+		s.complete = true
+	}
+}
+
+// field stops the sink and returns a field referencing the archived body, if the body exceeded LimitSize.
+func (s *archiveSink) field(name string) (zapcore.Field, bool) {
+	if s == nil || s.finished || (s.queue == nil && s.err == nil) {
+		s.discard()
+		return zap.Skip(), false
+	}
+
+	s.finished = true
+
+	if s.queue != nil {
+		close(s.queue)
+	}
+
+	return zap.Object(name, archivedBody{
+		ref:       s.ref,
+		size:      s.size,
+		hash:      s.hashText(),
+		truncated: s.truncated || !s.complete,
+		err:       s.err,
+	}), true
+}
+
+func (s *archiveSink) hashText() string {
+	if s.hash == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(s.hash.Sum(nil))
+}
+
+// discard stops the sink and removes the archived copy, unless it has been logged.
+func (s *archiveSink) discard() {
+	if s == nil || s.finished {
+		return
+	}
+
+	s.finished = true
+	s.pending = nil
+
+	if s.queue != nil {
+		s.removed = true
+		close(s.queue)
+	}
+}
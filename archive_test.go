@@ -0,0 +1,35 @@
+package echozapmiddleware
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempFileArchiverCleanup(t *testing.T) {
+	dir := t.TempDir()
+	archiver := TempFileArchiver{Dir: dir}
+
+	w, oldRef, err := archiver.Create(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, os.Chtimes(oldRef, time.Now(), time.Now().Add(-2*time.Hour)))
+
+	w, newRef, err := archiver.Create(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	other := filepath.Join(dir, "other.txt")
+	require.NoError(t, os.WriteFile(other, nil, 0o600))
+	require.NoError(t, os.Chtimes(other, time.Now(), time.Now().Add(-2*time.Hour)))
+
+	require.NoError(t, archiver.Cleanup(time.Hour))
+
+	require.NoFileExists(t, oldRef)
+	require.FileExists(t, newRef)
+	require.FileExists(t, other)
+}
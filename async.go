@@ -0,0 +1,128 @@
+package echozapmiddleware
+
+import "sync"
+
+const (
+	// DefaultAsyncQueueSize is the number of entries queued when AsyncConfig.QueueSize is not set.
+	DefaultAsyncQueueSize = 1024
+	// DefaultAsyncWorkers is the number of writing goroutines when AsyncConfig.Workers is not set.
+	DefaultAsyncWorkers = 1
+)
+
+// AsyncConfig defines the config for writing request entries in background goroutines.
+type AsyncConfig struct {
+	// Enabled writes request entries in background goroutines instead of the request goroutine.
+	// Entries are dropped and counted as "async_dropped" in the "echozap" expvar map when the queue is full.
+	// It requires a ConfigHolder (see MiddlewareWithConfigHolder), which has to be closed on shutdown,
+	// e.g. after echo.Echo.Shutdown, so the queued entries are written
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// QueueSize defines the number of entries waiting to be written. The default is DefaultAsyncQueueSize
+	QueueSize int `json:"queue_size,omitempty" yaml:"queue_size,omitempty"`
+
+	// Workers defines the number of writing goroutines. The default is DefaultAsyncWorkers.
+	// They are owned by the ConfigHolder of the middleware and are stopped, after writing the queued entries,
+	// when the holder is closed or gets a config with other Async settings
+	Workers int `json:"workers,omitempty" yaml:"workers,omitempty"`
+}
+
+// withDefaults returns c with the default queue size and workers applied.
+func (c AsyncConfig) withDefaults() AsyncConfig {
+	if c.QueueSize <= 0 {
+		c.QueueSize = DefaultAsyncQueueSize
+	}
+
+	if c.Workers <= 0 {
+		c.Workers = DefaultAsyncWorkers
+	}
+
+	return c
+}
+
+// asyncWriter writes entries from a bounded queue in background goroutines.
+type asyncWriter struct {
+	config AsyncConfig
+	queue  chan func()
+	wg     sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func newAsyncWriter(config AsyncConfig) *asyncWriter {
+	if !config.Enabled {
+		return nil
+	}
+
+	config = config.withDefaults()
+
+	w := &asyncWriter{config: config, queue: make(chan func(), config.QueueSize)}
+
+	w.wg.Add(config.Workers)
+
+	for range config.Workers {
+		go w.run()
+	}
+
+	return w
+}
+
+func (w *asyncWriter) run() {
+	defer w.wg.Done()
+
+	for write := range w.queue {
+		write()
+	}
+}
+
+// write queues write, or calls it right away if w is nil or stopped.
+// write is dropped if the queue is full.
+func (w *asyncWriter) write(write func()) {
+	if w == nil {
+		write()
+
+		return
+	}
+
+	w.mu.RLock()
+
+	if w.closed {
+		w.mu.RUnlock()
+		write()
+
+		return
+	}
+
+	select {
+	case w.queue <- write:
+	default:
+		stats.Add(statsAsyncDropped, 1)
+	}
+
+	w.mu.RUnlock()
+}
+
+// stop writes the queued entries and stops the goroutines. Entries written afterwards are written right away.
+func (w *asyncWriter) stop() {
+	if w == nil {
+		return
+	}
+
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
+
+// matches reports whether w writes entries with config, so it can be kept when the config is replaced.
+func (w *asyncWriter) matches(config AsyncConfig) bool {
+	return w != nil && config.Enabled && w.config == config.withDefaults()
+}
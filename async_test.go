@@ -0,0 +1,87 @@
+package echozapmiddleware
+
+import (
+	"expvar"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	require.Nil(t, newAsyncWriter(AsyncConfig{}))
+
+	var nilWriter *asyncWriter
+
+	written := 0
+	nilWriter.write(func() { written++ })
+	require.Equal(t, 1, written)
+
+	dropped := func() int64 {
+		if v, ok := stats.Get(statsAsyncDropped).(*expvar.Int); ok {
+			return v.Value()
+		}
+
+		return 0
+	}
+	before := dropped()
+
+	// no workers, so the queue is never drained
+	w := &asyncWriter{queue: make(chan func(), 1)}
+	w.write(func() {})
+	w.write(func() {})
+	require.Len(t, w.queue, 1)
+	require.Equal(t, before+1, dropped())
+
+	done := make(chan struct{})
+	w = newAsyncWriter(AsyncConfig{Enabled: true, Workers: 2})
+	w.write(func() { close(done) })
+	<-done
+	require.Equal(t, DefaultAsyncQueueSize, cap(w.queue))
+
+	w.stop()
+	w.stop()
+
+	// entries are written right away once the writer is stopped
+	written = 0
+	w.write(func() { written++ })
+	require.Equal(t, 1, written)
+}
+
+func TestConfigHolderAsyncWriter(t *testing.T) {
+	holder := NewConfigHolder(ZapConfig{Async: AsyncConfig{Enabled: true}})
+	first := holder.Config().asyncWriter
+	require.NotNil(t, first)
+
+	// the writer is kept while the async settings are the same
+	holder.SetConfig(ZapConfig{IsBodyDump: true, Async: AsyncConfig{Enabled: true, Workers: DefaultAsyncWorkers}})
+	require.Same(t, first, holder.Config().asyncWriter)
+
+	// queued entries of the replaced writer are written before it's stopped
+	release := make(chan struct{})
+	written := 0
+
+	first.write(func() { <-release })
+
+	for range 10 {
+		first.write(func() { written++ })
+	}
+
+	go close(release)
+
+	holder.SetConfig(ZapConfig{Async: AsyncConfig{Enabled: true, Workers: 2}})
+	require.Equal(t, 10, written)
+
+	second := holder.Config().asyncWriter
+	require.NotSame(t, first, second)
+
+	holder.SetConfig(ZapConfig{})
+	require.Nil(t, holder.Config().asyncWriter)
+	require.True(t, second.closed)
+
+	holder.SetConfig(ZapConfig{Async: AsyncConfig{Enabled: true}})
+	third := holder.Config().asyncWriter
+
+	holder.Close()
+	require.Nil(t, holder.Config().asyncWriter)
+	require.True(t, third.closed)
+}
@@ -0,0 +1,294 @@
+package echozapmiddleware
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const excludedValue = "[excluded]"
+
+// BodyFormat defines how bodies are formatted before truncation.
+type BodyFormat string
+
+const (
+	// BodyFormatAsIs logs bodies as they are.
+	BodyFormatAsIs BodyFormat = ""
+	// BodyFormatCompact removes insignificant whitespace from JSON and XML bodies.
+	BodyFormatCompact BodyFormat = "compact"
+	// BodyFormatPretty indents JSON and XML bodies.
+	BodyFormatPretty BodyFormat = "pretty"
+)
+
+// DefaultBodySkipContentTypes is the default list of binary content types which bodies are excluded.
+var DefaultBodySkipContentTypes = []string{
+	"image/*",
+	"audio/*",
+	"video/*",
+	"font/*",
+	echo.MIMEOctetStream,
+	"application/pdf",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-tar",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+}
+
+// preparedBody is a captured body prepared for logging.
+type preparedBody struct {
+	body      string
+	mediaType string
+	skip      bool
+}
+
+func addBody(
+	config ZapConfig,
+	c echo.Context,
+	reqBody []byte,
+	reqArchive *archiveSink,
+	respDumper *bodyDumper,
+) []zapcore.Field {
+	if !config.IsBodyDump || respDumper == nil {
+		return nil
+	}
+
+	skipReq, skipResp := config.BodySkipper(c)
+	fields := reqBodyFields(config, c, reqBody, reqArchive, skipReq)
+
+	if respDumper.stream != nil {
+		return append(fields,
+			zap.Int(config.FieldNames.RespEvents, respDumper.stream.events),
+			zap.Int64(config.FieldNames.RespBodySize, respDumper.size),
+			zap.Duration(config.FieldNames.RespStreamDuration, respDumper.stream.duration()),
+		)
+	}
+
+	resp := prepareBody(config, c.Response().Header(), respDumper.GetResponse(), skipResp)
+	fields = append(fields, bodyFields(config, config.FieldNames.RespBody, config.FieldNames.RespBodyEncoding, resp)...)
+
+	if resp.skip {
+		respDumper.archive.discard()
+	} else {
+		// the whole response has been written
+		respDumper.archive.end()
+
+		if field, ok := respDumper.archive.field(config.FieldNames.RespBodyRef); ok {
+			fields = append(fields, field)
+		}
+	}
+
+	if respDumper.truncated {
+		recordBodyStats(0, true)
+
+		fields = append(fields,
+			zap.Bool(config.FieldNames.RespBodyTruncated, true),
+			zap.Int64(config.FieldNames.RespBodySize, respDumper.size),
+		)
+	}
+
+	return fields
+}
+
+func reqBodyFields(config ZapConfig, c echo.Context, body []byte, archive *archiveSink, skip bool) []zapcore.Field {
+	req := c.Request()
+
+	var fields []zapcore.Field
+
+	if !skip && isRawBodyLogged(config, req.Header) {
+		fields = []zapcore.Field{zap.ByteString(config.FieldNames.ReqBody, limitBodyBytes(config, body))}
+	} else {
+		prepared := prepareBody(config, req.Header, string(body), skip)
+		if prepared.skip {
+			archive.discard()
+
+			return bodyFields(config, config.FieldNames.ReqBody, config.FieldNames.ReqBodyEncoding, prepared)
+		}
+
+		fields = bodyFields(config, config.FieldNames.ReqBody, config.FieldNames.ReqBodyEncoding, prepared)
+		if field, ok := multipartBodyField(config, req, prepared.body); ok {
+			fields = []zapcore.Field{field}
+		} else if field, ok := formBodyField(config, req, prepared.body); ok {
+			fields = []zapcore.Field{field}
+		}
+	}
+
+	if field, ok := archive.field(config.FieldNames.ReqBodyRef); ok {
+		fields = append(fields, field)
+	}
+
+	return fields
+}
+
+// isRawBodyLogged reports whether a body is logged as it was captured, so it doesn't have to be converted to a string.
+func isRawBodyLogged(config ZapConfig, header http.Header) bool {
+	encoding := header.Get(echo.HeaderContentEncoding)
+	if encoding != "" && !strings.EqualFold(encoding, "identity") {
+		return false
+	}
+
+	if config.BodyDecoder != nil || config.Base64BinaryBody || config.BodyAsJSON || config.BodyFormat != BodyFormatAsIs ||
+		len(config.redactJSONPaths) > 0 || len(config.RedactXMLElements) > 0 || len(config.BodyRedactors) > 0 {
+		return false
+	}
+
+	mediaType := parseMediaType(header.Get(echo.HeaderContentType))
+
+	return mediaType != echo.MIMEMultipartForm && mediaType != echo.MIMEApplicationForm &&
+		isContentTypeDumped(config, mediaType)
+}
+
+// prepareBody decompresses and decodes body according to headers
+// and checks whether it has to be excluded by its content type.
+func prepareBody(config ZapConfig, header http.Header, body string, skip bool) preparedBody {
+	contentType := header.Get(echo.HeaderContentType)
+	result := preparedBody{
+		body:      body,
+		mediaType: parseMediaType(contentType),
+		skip:      skip,
+	}
+
+	if skip {
+		return result
+	}
+
+	result.body = decompressBody(header.Get(echo.HeaderContentEncoding), body)
+
+	if config.BodyDecoder != nil {
+		if decoded, ok := config.BodyDecoder(contentType, []byte(result.body)); ok {
+			result.body = decoded
+			return result
+		}
+	}
+
+	result.skip = !isContentTypeDumped(config, result.mediaType)
+
+	return result
+}
+
+func bodyFields(config ZapConfig, name string, encodingName string, prepared preparedBody) []zapcore.Field {
+	if !prepared.skip && config.Base64BinaryBody && isBinary(prepared.body) {
+		return []zapcore.Field{
+			zap.String(name, limitBody(config, base64.StdEncoding.EncodeToString([]byte(prepared.body)))),
+			zap.String(encodingName, "base64"),
+		}
+	}
+
+	return []zapcore.Field{bodyField(config, name, prepared)}
+}
+
+// isBinary reports whether body is not valid UTF-8, ignoring a rune cut off by truncation.
+func isBinary(body string) bool {
+	for i := 0; i < utf8.UTFMax-1 && !utf8.ValidString(body); i++ {
+		body = body[:len(body)-1]
+	}
+
+	return !utf8.ValidString(body)
+}
+
+func bodyField(config ZapConfig, name string, prepared preparedBody) zapcore.Field {
+	body := prepared.body
+
+	if !prepared.skip {
+		if isXMLMediaType(prepared.mediaType) {
+			body = processXML(config.RedactXMLElements, config.BodyFormat, body)
+		} else {
+			body = redactJSON(config.redactJSONPaths, body)
+			body = formatBody(config.BodyFormat, body)
+		}
+
+		body = redactBody(config.BodyRedactors, body)
+	}
+
+	if prepared.skip {
+		if len(body) > 0 {
+			body = excludedValue
+		}
+	} else {
+		body = limitBody(config, body)
+	}
+
+	if config.BodyAsJSON && !prepared.skip && looksLikeJSON(body) && json.Valid([]byte(body)) {
+		return zap.Reflect(name, json.RawMessage(body))
+	}
+
+	return zap.String(name, body)
+}
+
+func isContentTypeDumped(config ZapConfig, mediaType string) bool {
+	if mediaType == "" {
+		return true
+	}
+
+	if len(config.BodyDumpContentTypes) > 0 && !matchMediaType(config.BodyDumpContentTypes, mediaType) {
+		return false
+	}
+
+	return !matchMediaType(config.BodySkipContentTypes, mediaType)
+}
+
+func parseMediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, _, _ = strings.Cut(contentType, ";")
+	}
+
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// matchMediaType reports whether mediaType matches any of patterns, e.g. "image/png" matches "image/*".
+func matchMediaType(patterns []string, mediaType string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+
+			continue
+		}
+
+		if pattern == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func formatBody(format BodyFormat, body string) string {
+	if format == BodyFormatAsIs || !looksLikeJSON(body) {
+		return body
+	}
+
+	var (
+		buf bytes.Buffer
+		err error
+	)
+
+	switch format {
+	case BodyFormatCompact:
+		err = json.Compact(&buf, []byte(body))
+	case BodyFormatPretty:
+		err = json.Indent(&buf, []byte(body), "", "  ")
+	default:
+		return body
+	}
+
+	if err != nil {
+		return body
+	}
+
+	return buf.String()
+}
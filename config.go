@@ -0,0 +1,250 @@
+package echozapmiddleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var (
+	// ErrNegativeLimitSize is returned when LimitSize is negative.
+	ErrNegativeLimitSize = errors.New("limit size must not be negative")
+	// ErrMissingLimitSize is returned when LimitHTTPBody is set without LimitSize.
+	ErrMissingLimitSize = errors.New("limit size must be set when http body is limited")
+	// ErrNegativeMaxCapturedBytes is returned when MaxCapturedResponseBytes is negative.
+	ErrNegativeMaxCapturedBytes = errors.New("max captured response bytes must not be negative")
+	// ErrMissingBodySkipper is returned when IsBodyDump is set without BodySkipper.
+	ErrMissingBodySkipper = errors.New("body skipper must be set when body is dumped")
+	// ErrInvalidSampleRate is returned when a sample rate is not within [0, 1].
+	ErrInvalidSampleRate = errors.New("sample rate must be within [0, 1]")
+	// ErrNegativeMaxLogsPerSecond is returned when MaxLogsPerSecond is negative.
+	ErrNegativeMaxLogsPerSecond = errors.New("max logs per second must not be negative")
+	// ErrUnknownFieldConvention is returned when FieldConvention is not supported.
+	ErrUnknownFieldConvention = errors.New("unknown field convention")
+	// ErrUnknownAccessLogFormat is returned when AccessLogFormat is not supported.
+	ErrUnknownAccessLogFormat = errors.New("unknown access log format")
+	// ErrUnknownLatencyFormat is returned when LatencyFormat is not supported.
+	ErrUnknownLatencyFormat = errors.New("unknown latency format")
+	// ErrNegativeAsyncSettings is returned when the queue size or the workers of Async are negative.
+	ErrNegativeAsyncSettings = errors.New("async queue size and workers must not be negative")
+	// ErrMissingDebugSecret is returned when DebugHeader is set without DebugSecret.
+	ErrMissingDebugSecret = errors.New("debug secret must be set when debug header is set")
+	// ErrEMFWithDroppedEntries is returned when EMF is enabled with settings dropping entries.
+	ErrEMFWithDroppedEntries = errors.New("emf must not be combined with sampling, rate limit, dedup or skipped statuses")
+	// ErrAsyncWithoutConfigHolder is logged by Middleware and MiddlewareWithContextLogger when Async is enabled,
+	// as their background writers could never be stopped, so they write entries synchronously.
+	ErrAsyncWithoutConfigHolder = errors.New("async requires a config holder, which can be closed on shutdown")
+	// ErrInvalidDuration is returned when a JSON duration is neither a string like "1s" nor integer nanoseconds.
+	ErrInvalidDuration = errors.New("duration must be a string like \"1s\" or integer nanoseconds")
+)
+
+// NewConfig validates config and returns it with defaults applied.
+func NewConfig(config ZapConfig) (ZapConfig, error) {
+	if err := config.Validate(); err != nil {
+		return ZapConfig{}, err
+	}
+
+	return prepareConfig(config), nil
+}
+
+// Validate checks config for inconsistent settings.
+func (config ZapConfig) Validate() error {
+	var errs []error
+
+	if config.LimitSize < 0 {
+		errs = append(errs, ErrNegativeLimitSize)
+	}
+
+	if config.LimitHTTPBody && config.LimitSize == 0 {
+		errs = append(errs, ErrMissingLimitSize)
+	}
+
+	if config.MaxCapturedResponseBytes < 0 {
+		errs = append(errs, ErrNegativeMaxCapturedBytes)
+	}
+
+	if config.IsBodyDump && config.BodySkipper == nil {
+		errs = append(errs, ErrMissingBodySkipper)
+	}
+
+	if config.SuccessSampleRate < 0 || config.SuccessSampleRate > 1 {
+		errs = append(errs, ErrInvalidSampleRate)
+	}
+
+	for route, rate := range config.RouteSampleRates {
+		if rate < 0 || rate > 1 {
+			errs = append(errs, fmt.Errorf("%w: %q", ErrInvalidSampleRate, route))
+		}
+	}
+
+	if config.MaxLogsPerSecond < 0 {
+		errs = append(errs, ErrNegativeMaxLogsPerSecond)
+	}
+
+	if config.FieldConvention != FieldConventionDefault && config.FieldConvention != FieldConventionSemConv {
+		errs = append(errs, fmt.Errorf("%w: %q", ErrUnknownFieldConvention, config.FieldConvention))
+	}
+
+	if config.AccessLogFormat != AccessLogFormatDefault && config.AccessLogFormat != AccessLogFormatCombined {
+		errs = append(errs, fmt.Errorf("%w: %q", ErrUnknownAccessLogFormat, config.AccessLogFormat))
+	}
+
+	if !config.LatencyFormat.valid() {
+		errs = append(errs, fmt.Errorf("%w: %q", ErrUnknownLatencyFormat, config.LatencyFormat))
+	}
+
+	if config.Async.QueueSize < 0 || config.Async.Workers < 0 {
+		errs = append(errs, ErrNegativeAsyncSettings)
+	}
+
+	if config.DebugHeader != "" && config.DebugSecret == "" {
+		errs = append(errs, ErrMissingDebugSecret)
+	}
+
+	if config.EMF.dropsEntries(config) {
+		errs = append(errs, ErrEMFWithDroppedEntries)
+	}
+
+	return errors.Join(errs...)
+}
+
+type configAlias ZapConfig
+
+// configFile is the (un)marshalled representation of ZapConfig.
+type configFile struct {
+	configAlias `yaml:",inline"`
+
+	SkipPathRegexps []string `json:"skip_path_regexps,omitempty" yaml:"skip_path_regexps,omitempty"`
+}
+
+func (config ZapConfig) toFile() configFile {
+	file := configFile{configAlias: configAlias(config)}
+
+	for _, rx := range config.SkipPathRegexps {
+		file.SkipPathRegexps = append(file.SkipPathRegexps, rx.String())
+	}
+
+	return file
+}
+
+func (config *ZapConfig) fromFile(file configFile) error {
+	regexps := make([]*regexp.Regexp, 0, len(file.SkipPathRegexps))
+
+	for _, expr := range file.SkipPathRegexps {
+		rx, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("skip path regexp: %w", err)
+		}
+
+		regexps = append(regexps, rx)
+	}
+
+	*config = ZapConfig(file.configAlias)
+
+	if file.SkipPathRegexps != nil {
+		config.SkipPathRegexps = regexps
+	}
+
+	return nil
+}
+
+// jsonDuration is a time.Duration (un)marshalled as a string like "1s", as YAML does.
+// Integer nanoseconds are accepted as well.
+type jsonDuration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String()) //nolint:wrapcheck
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var nanoseconds int64
+	if err := json.Unmarshal(data, &nanoseconds); err == nil {
+		*d = jsonDuration(nanoseconds)
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDuration, data)
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidDuration, err)
+	}
+
+	*d = jsonDuration(duration)
+
+	return nil
+}
+
+// jsonConfigFile is configFile with the durations (un)marshalled as strings.
+type jsonConfigFile struct {
+	configFile
+
+	LongRunningInterval  jsonDuration `json:"long_running_interval,omitempty"`
+	SlowRequestThreshold jsonDuration `json:"slow_request_threshold,omitempty"`
+	DedupWindow          jsonDuration `json:"dedup_window,omitempty"`
+}
+
+func (config ZapConfig) toJSONFile() jsonConfigFile {
+	return jsonConfigFile{
+		configFile:           config.toFile(),
+		LongRunningInterval:  jsonDuration(config.LongRunningInterval),
+		SlowRequestThreshold: jsonDuration(config.SlowRequestThreshold),
+		DedupWindow:          jsonDuration(config.DedupWindow),
+	}
+}
+
+// MarshalJSON implements json.Marshaler. DebugSecret is redacted.
+func (config ZapConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(config.redactSecrets().toJSONFile()) //nolint:wrapcheck
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// Fields missing in data keep their current values, so a config can be loaded on top of DefaultZapConfig.
+func (config *ZapConfig) UnmarshalJSON(data []byte) error {
+	file := config.toJSONFile()
+	file.SkipPathRegexps = nil
+
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	file.configAlias.LongRunningInterval = time.Duration(file.LongRunningInterval)
+	file.configAlias.SlowRequestThreshold = time.Duration(file.SlowRequestThreshold)
+	file.configAlias.DedupWindow = time.Duration(file.DedupWindow)
+
+	return config.fromFile(file.configFile)
+}
+
+// MarshalYAML implements yaml.Marshaler. DebugSecret is redacted.
+func (config ZapConfig) MarshalYAML() (any, error) {
+	return config.redactSecrets().toFile(), nil
+}
+
+// redactSecrets returns config with its secrets redacted, so marshalled configs can be shared.
+func (config ZapConfig) redactSecrets() ZapConfig {
+	if config.DebugSecret != "" {
+		config.DebugSecret = redactedValue
+	}
+
+	return config
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+// Fields missing in data keep their current values, so a config can be loaded on top of DefaultZapConfig.
+func (config *ZapConfig) UnmarshalYAML(unmarshal func(any) error) error {
+	file := config.toFile()
+	file.SkipPathRegexps = nil
+
+	if err := unmarshal(&file); err != nil {
+		return err
+	}
+
+	return config.fromFile(file)
+}
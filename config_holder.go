@@ -0,0 +1,70 @@
+package echozapmiddleware
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ConfigHolder holds a ZapConfig which can be swapped at runtime,
+// e.g. to turn body or header dumping on and off in a running service.
+type ConfigHolder struct {
+	config atomic.Pointer[ZapConfig]
+
+	mu          sync.Mutex
+	asyncWriter *asyncWriter
+}
+
+// NewConfigHolder returns a ConfigHolder with config.
+// If config is not passed, DefaultZapConfig will be used.
+func NewConfigHolder(config ...ZapConfig) *ConfigHolder {
+	if len(config) == 0 {
+		config = []ZapConfig{DefaultZapConfig}
+	}
+
+	holder := &ConfigHolder{}
+	holder.SetConfig(config[0])
+
+	return holder
+}
+
+// SetConfig replaces the config used by the middleware.
+// It is safe to call SetConfig concurrently with running requests.
+// The background writers of the previous config are kept if its Async settings are the same,
+// otherwise they are stopped after writing the queued entries.
+func (h *ConfigHolder) SetConfig(config ZapConfig) {
+	config = prepareConfig(config)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	previous := h.asyncWriter
+	if !previous.matches(config.Async) {
+		h.asyncWriter = newAsyncWriter(config.Async)
+	}
+
+	config.asyncWriter = h.asyncWriter
+	h.config.Store(&config)
+
+	if previous != h.asyncWriter {
+		previous.stop()
+	}
+}
+
+// Close writes the queued entries of Async and stops its background writers.
+// Entries of later requests are written synchronously.
+func (h *ConfigHolder) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	config := h.Config()
+	config.asyncWriter = nil
+	h.config.Store(&config)
+
+	h.asyncWriter.stop()
+	h.asyncWriter = nil
+}
+
+// Config returns the current config.
+func (h *ConfigHolder) Config() ZapConfig {
+	return *h.config.Load()
+}
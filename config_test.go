@@ -0,0 +1,228 @@
+package echozapmiddleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestNewConfig(t *testing.T) {
+	t.Run("default config", func(t *testing.T) {
+		config, err := NewConfig(DefaultZapConfig)
+		require.NoError(t, err)
+		require.NotNil(t, config.Skipper)
+		require.NotNil(t, config.LevelFunc)
+		require.Equal(t, DefaultFieldNames, config.FieldNames)
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{
+			IsBodyDump:    true,
+			LimitHTTPBody: true,
+			LimitSize:     -1,
+		})
+		require.ErrorIs(t, err, ErrNegativeLimitSize)
+		require.ErrorIs(t, err, ErrMissingBodySkipper)
+		require.NotErrorIs(t, err, ErrMissingLimitSize)
+
+		_, err = NewConfig(ZapConfig{LimitHTTPBody: true})
+		require.ErrorIs(t, err, ErrMissingLimitSize)
+	})
+
+	t.Run("body dump with skipper", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{
+			IsBodyDump: true,
+			BodySkipper: func(echo.Context) (bool, bool) {
+				return false, false
+			},
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("semconv field names", func(t *testing.T) {
+		config, err := NewConfig(ZapConfig{
+			FieldConvention: FieldConventionSemConv,
+			FieldNames:      FieldNames{Latency: "duration"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, "http.request.method", config.FieldNames.Method)
+		require.Equal(t, "client.address", config.FieldNames.RemoteIP)
+		require.Equal(t, "url.path", config.FieldNames.URI)
+		require.Equal(t, "url.query", config.FieldNames.URLQuery)
+		require.Empty(t, DefaultFieldNames.URLQuery)
+		require.Equal(t, "duration", config.FieldNames.Latency)
+		require.Equal(t, DefaultFieldNames.RequestID, config.FieldNames.RequestID)
+
+		_, err = NewConfig(ZapConfig{FieldConvention: "ecs"})
+		require.ErrorIs(t, err, ErrUnknownFieldConvention)
+	})
+
+	t.Run("invalid sample rate", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{SuccessSampleRate: 1.5})
+		require.ErrorIs(t, err, ErrInvalidSampleRate)
+
+		_, err = NewConfig(ZapConfig{RouteSampleRates: map[string]float64{"/ping": -1}})
+		require.ErrorIs(t, err, ErrInvalidSampleRate)
+	})
+
+	t.Run("unknown access log format", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{AccessLogFormat: "common"})
+		require.ErrorIs(t, err, ErrUnknownAccessLogFormat)
+	})
+
+	t.Run("unknown latency format", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{LatencyFormat: "min"})
+		require.ErrorIs(t, err, ErrUnknownLatencyFormat)
+	})
+
+	t.Run("negative async workers", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{Async: AsyncConfig{Enabled: true, Workers: -1}})
+		require.ErrorIs(t, err, ErrNegativeAsyncSettings)
+	})
+
+	t.Run("debug header without secret", func(t *testing.T) {
+		_, err := NewConfig(ZapConfig{DebugHeader: "X-Debug-Log"})
+		require.ErrorIs(t, err, ErrMissingDebugSecret)
+	})
+
+	t.Run("emf with dropped entries", func(t *testing.T) {
+		emf := EMFConfig{Enabled: true}
+
+		for _, config := range []ZapConfig{
+			{EMF: emf, SuccessSampleRate: 0.1},
+			{EMF: emf, RouteSampleRates: map[string]float64{"/health": 0}},
+			{EMF: emf, MaxLogsPerSecond: 100},
+			{EMF: emf, DedupWindow: time.Second},
+			{EMF: emf, SkipStatusCodes: []int{http.StatusNotFound}},
+		} {
+			_, err := NewConfig(config)
+			require.ErrorIs(t, err, ErrEMFWithDroppedEntries)
+		}
+
+		_, err := NewConfig(ZapConfig{EMF: emf, SuccessSampleRate: 1})
+		require.NoError(t, err)
+	})
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		config, err := ConfigFromEnv("")
+		require.NoError(t, err)
+		require.Equal(t, DefaultZapConfig.LimitSize, config.LimitSize)
+		require.False(t, config.IsBodyDump)
+	})
+
+	t.Run("overridden", func(t *testing.T) {
+		t.Setenv("ECHOZAP_BODY_DUMP", "true")
+		t.Setenv("ECHOZAP_HEADERS_DUMP", "1")
+		t.Setenv("ECHOZAP_LIMIT_SIZE", "1024")
+		t.Setenv("ECHOZAP_SKIP_PATHS", "/metrics, /health*")
+		t.Setenv("ECHOZAP_SKIP_STATUS_CODES", "304,404")
+
+		config, err := ConfigFromEnv("")
+		require.NoError(t, err)
+		require.True(t, config.IsBodyDump)
+		require.True(t, config.AreHeadersDump)
+		require.Equal(t, 1024, config.LimitSize)
+		require.Equal(t, []string{"/metrics", "/health*"}, config.SkipPaths)
+		require.Equal(t, []int{304, 404}, config.SkipStatusCodes)
+	})
+
+	t.Run("custom prefix", func(t *testing.T) {
+		t.Setenv("APP_LOG_LIMIT_HTTP_BODY", "false")
+
+		config, err := ConfigFromEnv("APP_LOG")
+		require.NoError(t, err)
+		require.False(t, config.LimitHTTPBody)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		t.Setenv("ECHOZAP_LIMIT_SIZE", "big")
+
+		_, err := ConfigFromEnv("")
+		require.ErrorContains(t, err, "ECHOZAP_LIMIT_SIZE")
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		t.Setenv("ECHOZAP_LIMIT_SIZE", "-1")
+
+		_, err := ConfigFromEnv("")
+		require.ErrorIs(t, err, ErrNegativeLimitSize)
+	})
+}
+
+func TestConfigMarshalling(t *testing.T) {
+	config := DefaultZapConfig
+	config.IsBodyDump = true
+	config.SkipPaths = []string{"/metrics"}
+	config.SkipPathRegexps = []*regexp.Regexp{regexp.MustCompile("^/health")}
+	config.FieldNames.Status = "http.status"
+	config.DebugHeader = "X-Debug-Log"
+	config.DebugSecret = "s3cret"
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(config)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"skip_path_regexps":["^/health"]`)
+		require.Contains(t, string(data), `"debug_secret":"[redacted]"`)
+		require.NotContains(t, string(data), "s3cret")
+
+		loaded := DefaultZapConfig
+		require.NoError(t, json.Unmarshal(data, &loaded))
+		require.True(t, loaded.IsBodyDump)
+		require.Equal(t, config.SkipPaths, loaded.SkipPaths)
+		require.Equal(t, "^/health", loaded.SkipPathRegexps[0].String())
+		require.Equal(t, "http.status", loaded.FieldNames.Status)
+		require.NotNil(t, loaded.BodySkipper)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		data, err := yaml.Marshal(config)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "debug_secret: '[redacted]'")
+		require.NotContains(t, string(data), "s3cret")
+
+		loaded := DefaultZapConfig
+		require.NoError(t, yaml.Unmarshal(data, &loaded))
+		require.True(t, loaded.IsBodyDump)
+		require.Equal(t, config.SkipPaths, loaded.SkipPaths)
+		require.Equal(t, "^/health", loaded.SkipPathRegexps[0].String())
+		require.Equal(t, "http.status", loaded.FieldNames.Status)
+	})
+
+	t.Run("partial yaml", func(t *testing.T) {
+		loaded := DefaultZapConfig
+		require.NoError(t, yaml.Unmarshal([]byte("body_dump: true\nlimit_size: 100\n"), &loaded))
+		require.True(t, loaded.IsBodyDump)
+		require.True(t, loaded.LimitHTTPBody)
+		require.Equal(t, 100, loaded.LimitSize)
+	})
+
+	t.Run("json durations", func(t *testing.T) {
+		loaded := DefaultZapConfig
+		require.NoError(t, json.Unmarshal(
+			[]byte(`{"long_running_interval":"1s","slow_request_threshold":"250ms","dedup_window":60000000000}`),
+			&loaded,
+		))
+		require.Equal(t, time.Second, loaded.LongRunningInterval)
+		require.Equal(t, 250*time.Millisecond, loaded.SlowRequestThreshold)
+		require.Equal(t, time.Minute, loaded.DedupWindow)
+
+		data, err := json.Marshal(loaded)
+		require.NoError(t, err)
+		require.Contains(t, string(data), `"long_running_interval":"1s"`)
+
+		require.Error(t, json.Unmarshal([]byte(`{"dedup_window":"soon"}`), &loaded))
+	})
+
+	t.Run("invalid regexp", func(t *testing.T) {
+		loaded := DefaultZapConfig
+		require.Error(t, json.Unmarshal([]byte(`{"skip_path_regexps":["("]}`), &loaded))
+	})
+}
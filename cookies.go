@@ -0,0 +1,107 @@
+package echozapmiddleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"slices"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultSensitiveCookies is the default list of cookies which values are always redacted.
+var DefaultSensitiveCookies = []string{
+	"session",
+	"sessionid",
+	"session_id",
+	"sid",
+	"token",
+	"access_token",
+	"refresh_token",
+	"auth",
+	"jwt",
+}
+
+// CookieConfig defines the config for logging request cookies.
+// Cookie names are always logged, values are redacted unless allowed by ValuesToLog.
+type CookieConfig struct {
+	// Enabled adds request cookies to the log entry
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// ValuesToLog defines cookies which values are logged. Use "*" to log all values
+	ValuesToLog []string `json:"values_to_log,omitempty" yaml:"values_to_log,omitempty"`
+
+	// SensitiveCookies defines cookies which values are always redacted.
+	// If nil, DefaultSensitiveCookies are used
+	SensitiveCookies []string `json:"sensitive_cookies,omitempty" yaml:"sensitive_cookies,omitempty"`
+}
+
+func (c CookieConfig) prepare() CookieConfig {
+	if c.SensitiveCookies == nil {
+		c.SensitiveCookies = DefaultSensitiveCookies
+	}
+
+	return c
+}
+
+func (c CookieConfig) isValueLogged(name string) bool {
+	if slices.Contains(c.SensitiveCookies, name) {
+		return false
+	}
+
+	return slices.Contains(c.ValuesToLog, "*") || slices.Contains(c.ValuesToLog, name)
+}
+
+func addCookies(config ZapConfig, req *http.Request) []zapcore.Field {
+	if !config.Cookies.Enabled {
+		return nil
+	}
+
+	cookies := req.Cookies()
+	if len(cookies) == 0 {
+		return nil
+	}
+
+	return []zapcore.Field{zap.Object(config.FieldNames.ReqCookies, loggedCookies{config: config.Cookies, cookies: cookies})}
+}
+
+// loggedCookies marshals cookies with the values which aren't logged redacted.
+// It holds only the cookie config, so the whole config doesn't escape to the heap.
+type loggedCookies struct {
+	config  CookieConfig
+	cookies []*http.Cookie
+}
+
+func (l loggedCookies) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, cookie := range l.cookies {
+		value := redactedValue
+		if l.config.isValueLogged(cookie.Name) {
+			value = cookie.Value
+		}
+
+		enc.AddString(cookie.Name, value)
+	}
+
+	return nil
+}
+
+// addSessionID returns the SHA-256 hash of the session cookie, or its value if RawSessionID is set.
+func addSessionID(config ZapConfig, req *http.Request) []zapcore.Field {
+	if config.SessionCookieName == "" {
+		return nil
+	}
+
+	cookie, err := req.Cookie(config.SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+
+	value := cookie.Value
+	if !config.RawSessionID {
+		sum := sha256.Sum256([]byte(value))
+		value = hex.EncodeToString(sum[:])
+	}
+
+	return []zapcore.Field{zap.String(config.FieldNames.SessionID, value)}
+}
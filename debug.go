@@ -0,0 +1,46 @@
+package echozapmiddleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+)
+
+// isDebugRequest reports whether the request asks for verbose logging with DebugHeader,
+// whose value must match DebugSecret. Without DebugSecret, DebugHeader has no effect.
+func isDebugRequest(config ZapConfig, req *http.Request) bool {
+	if config.DebugHeader == "" || config.DebugSecret == "" {
+		return false
+	}
+
+	value := req.Header.Get(config.DebugHeader)
+
+	return value != "" && subtle.ConstantTimeCompare([]byte(value), []byte(config.DebugSecret)) == 1
+}
+
+// debugConfig returns config with headers and bodies dumped for a debug request.
+// Sampling, deduplication and the rate limit don't drop the entry of a debug request either.
+func debugConfig(config ZapConfig, req *http.Request) ZapConfig {
+	if !isDebugRequest(config, req) {
+		return config
+	}
+
+	config.AreHeadersDump = true
+	config.IsBodyDump = true
+	config.SkipStatusCodes = nil
+	config.SuccessSampleRate = 0
+	config.routeSampler = nil
+	config.deduplicator = nil
+	config.logLimiter = nil
+
+	return config
+}
+
+// sensitiveHeaderNames returns SensitiveHeaders, adding DebugHeader, which carries DebugSecret.
+func sensitiveHeaderNames(config ZapConfig) []string {
+	if config.DebugHeader == "" {
+		return config.SensitiveHeaders
+	}
+
+	return append(slices.Clip(config.SensitiveHeaders), config.DebugHeader)
+}
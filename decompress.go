@@ -0,0 +1,61 @@
+package echozapmiddleware
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// maxDecompressedBodySize limits the size of a decompressed body copy to protect against compression bombs.
+const maxDecompressedBodySize = 1 << 20
+
+// decompressBody decompresses body according to the Content-Encoding header value.
+// If body can't be decompressed, it's returned as is.
+func decompressBody(encoding string, body string) string {
+	if body == "" {
+		return body
+	}
+
+	reader, err := newDecompressor(strings.ToLower(strings.TrimSpace(encoding)), strings.NewReader(body))
+	if err != nil || reader == nil {
+		return body
+	}
+
+	defer reader.Close()
+
+	decompressed, err := io.ReadAll(io.LimitReader(reader, maxDecompressedBodySize))
+	// a truncated stream is still worth logging
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) || len(decompressed) == 0 {
+		return body
+	}
+
+	return string(decompressed)
+}
+
+func newDecompressor(encoding string, body *strings.Reader) (io.ReadCloser, error) {
+	switch encoding {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body) //nolint:wrapcheck
+	case "deflate":
+		// deflate is expected to be zlib wrapped, but some clients send raw deflate streams
+		reader, err := zlib.NewReader(body)
+		if err == nil {
+			return reader, nil
+		}
+
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err //nolint:wrapcheck
+		}
+
+		return flate.NewReader(body), nil
+	case "br":
+		return io.NopCloser(brotli.NewReader(body)), nil
+	default:
+		return nil, nil
+	}
+}
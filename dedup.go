@@ -0,0 +1,100 @@
+package echozapmiddleware
+
+import (
+	"container/list"
+	"hash/maphash"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxDedupEntries is the number of tracked entries. The oldest ones are evicted beyond it.
+const maxDedupEntries = 1024
+
+// deduplicator collapses identical error entries within a time window.
+type deduplicator struct {
+	window time.Duration
+	seed   maphash.Seed
+	mu     sync.Mutex
+	// entries are ordered by the time they were last logged, the oldest first
+	entries map[dedupKey]*list.Element
+	order   *list.List
+}
+
+// dedupKey identifies identical entries. The error message is hashed,
+// as it may hold client input and would otherwise be kept in full.
+type dedupKey struct {
+	route   string
+	status  int
+	message uint64
+}
+
+type dedupEntry struct {
+	key     dedupKey
+	until   time.Time
+	repeats int64
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	if window <= 0 {
+		return nil
+	}
+
+	return &deduplicator{
+		window:  window,
+		seed:    maphash.MakeSeed(),
+		entries: make(map[dedupKey]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// check reports whether an error entry has to be logged. If so, it also returns
+// the number of identical entries dropped within the previous window.
+func (d *deduplicator) check(route string, status int, err error, now time.Time) (bool, int64) {
+	if d == nil || (err == nil && status < http.StatusBadRequest) {
+		return true, 0
+	}
+
+	key := dedupKey{route: route, status: status}
+	if err != nil {
+		key.message = maphash.String(d.seed, err.Error())
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.entries[key]
+	if ok {
+		if entry, _ := elem.Value.(*dedupEntry); now.Before(entry.until) {
+			entry.repeats++
+			return false, 0
+		}
+
+		d.order.MoveToBack(elem)
+	} else {
+		d.evict(now)
+
+		elem = d.order.PushBack(&dedupEntry{key: key})
+		d.entries[key] = elem
+	}
+
+	entry, _ := elem.Value.(*dedupEntry)
+	repeats := entry.repeats
+	entry.until = now.Add(d.window)
+	entry.repeats = 0
+
+	return true, repeats
+}
+
+// evict removes the expired entries and, if there are still too many, the oldest one.
+func (d *deduplicator) evict(now time.Time) {
+	for elem := d.order.Front(); elem != nil; elem = d.order.Front() {
+		entry, _ := elem.Value.(*dedupEntry)
+		if now.Before(entry.until) && d.order.Len() < maxDedupEntries {
+			return
+		}
+
+		d.order.Remove(elem)
+		delete(d.entries, entry.key)
+	}
+}
@@ -0,0 +1,56 @@
+package echozapmiddleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	d := newDeduplicator(time.Minute)
+	now := time.Now()
+	errUpstream := errors.New("upstream failed")
+
+	logged, repeats := d.check("GET /a", http.StatusBadGateway, errUpstream, now)
+	require.True(t, logged)
+	require.Zero(t, repeats)
+
+	for i := 0; i < 3; i++ {
+		logged, _ = d.check("GET /a", http.StatusBadGateway, errUpstream, now.Add(time.Second))
+		require.False(t, logged)
+	}
+
+	logged, _ = d.check("GET /a", http.StatusBadGateway, errors.New("timeout"), now.Add(time.Second))
+	require.True(t, logged)
+
+	logged, _ = d.check("GET /a", http.StatusOK, nil, now.Add(time.Second))
+	require.True(t, logged)
+
+	logged, repeats = d.check("GET /a", http.StatusBadGateway, errUpstream, now.Add(time.Minute))
+	require.True(t, logged)
+	require.EqualValues(t, 3, repeats)
+}
+
+func TestDeduplicatorEviction(t *testing.T) {
+	d := newDeduplicator(time.Minute)
+	now := time.Now()
+
+	for i := 0; i < maxDedupEntries+10; i++ {
+		logged, _ := d.check("GET /a", http.StatusBadRequest, fmt.Errorf("invalid id %d", i), now)
+		require.True(t, logged)
+	}
+
+	require.Len(t, d.entries, maxDedupEntries)
+	require.Equal(t, maxDedupEntries, d.order.Len())
+
+	// the oldest entries are evicted, the newest are still collapsed
+	logged, _ := d.check("GET /a", http.StatusBadRequest, errors.New("invalid id 0"), now)
+	require.True(t, logged)
+
+	logged, _ = d.check("GET /a", http.StatusBadRequest, fmt.Errorf("invalid id %d", maxDedupEntries+9), now)
+	require.False(t, logged)
+}
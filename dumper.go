@@ -0,0 +1,138 @@
+package echozapmiddleware
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// bodyDumper is a response writer which keeps a copy of the response body.
+// At most limit bytes are kept, unless limit is zero.
+// Server-sent events streams are not kept, only their stats are collected.
+type bodyDumper struct {
+	http.ResponseWriter
+	buf       bytes.Buffer
+	limit     int
+	size      int64
+	truncated bool
+	started   bool
+	stream    *streamStats
+	// archive receives the whole response body, unless it's a stream
+	archive *archiveSink
+}
+
+// streamStats holds stats of a server-sent events stream.
+type streamStats struct {
+	events   int
+	first    time.Time
+	last     time.Time
+	lastByte byte
+}
+
+func (s *streamStats) add(b []byte) {
+	s.last = time.Now()
+
+	// every event ends with a blank line
+	for _, c := range b {
+		if c == '\r' {
+			continue
+		}
+
+		if c == '\n' && s.lastByte == '\n' {
+			s.events++
+		}
+
+		s.lastByte = c
+	}
+}
+
+func (s *streamStats) duration() time.Duration {
+	return s.last.Sub(s.first)
+}
+
+func newBodyDumper(w http.ResponseWriter, limit int) *bodyDumper {
+	d, _ := bodyDumperPool.Get().(*bodyDumper)
+	d.ResponseWriter = w
+	d.limit = limit
+
+	return d
+}
+
+func (d *bodyDumper) Write(b []byte) (int, error) {
+	n, err := d.ResponseWriter.Write(b)
+	d.capture(b[:n])
+
+	if err != nil {
+		err = fmt.Errorf("error writing response: %w", err)
+	}
+
+	return n, err
+}
+
+func (d *bodyDumper) capture(b []byte) {
+	d.size += int64(len(b))
+
+	if !d.started {
+		d.started = true
+
+		mediaType := parseMediaType(d.Header().Get(echo.HeaderContentType))
+		if mediaType == "text/event-stream" {
+			d.stream = &streamStats{first: time.Now()}
+		}
+
+		d.archive = d.archive.forMediaType(mediaType)
+	}
+
+	if d.stream != nil {
+		d.stream.add(b)
+		return
+	}
+
+	d.archive.write(b)
+
+	if d.limit > 0 && d.buf.Len()+len(b) > d.limit {
+		b = b[:d.limit-d.buf.Len()]
+		d.truncated = true
+	}
+
+	d.buf.Write(b)
+}
+
+// GetResponse returns the captured response body.
+func (d *bodyDumper) GetResponse() string {
+	return d.buf.String()
+}
+
+// Flush implements http.Flusher, so streaming responses keep working.
+func (d *bodyDumper) Flush() {
+	_ = http.NewResponseController(d.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker, so websocket handlers keep working.
+func (d *bodyDumper) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(d.ResponseWriter).Hijack()
+	if err != nil {
+		err = fmt.Errorf("error hijacking response: %w", err)
+	}
+
+	return conn, rw, err
+}
+
+// Push implements http.Pusher for HTTP/2 server push.
+func (d *bodyDumper) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := d.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts) //nolint:wrapcheck
+	}
+
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the original response writer, so http.ResponseController can reach it.
+func (d *bodyDumper) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
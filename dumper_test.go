@@ -0,0 +1,82 @@
+package echozapmiddleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+type fullWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	pushed   string
+}
+
+func (w *fullWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return nil, nil, nil
+}
+
+func (w *fullWriter) Push(target string, _ *http.PushOptions) error {
+	w.pushed = target
+	return nil
+}
+
+func TestBodyDumperPassthrough(t *testing.T) {
+	t.Run("supported", func(t *testing.T) {
+		w := &fullWriter{ResponseRecorder: httptest.NewRecorder()}
+		d := newBodyDumper(w, 0)
+
+		_, err := d.Write([]byte("data"))
+		require.NoError(t, err)
+
+		http.Flusher(d).Flush()
+		require.True(t, w.Flushed)
+
+		_, _, err = http.Hijacker(d).Hijack()
+		require.NoError(t, err)
+		require.True(t, w.hijacked)
+
+		require.NoError(t, http.Pusher(d).Push("/app.js", nil))
+		require.Equal(t, "/app.js", w.pushed)
+
+		require.Equal(t, "data", d.GetResponse())
+		require.Equal(t, "data", w.Body.String())
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		d := newBodyDumper(httptest.NewRecorder(), 0)
+
+		_, _, err := d.Hijack()
+		require.ErrorIs(t, err, http.ErrNotSupported)
+		require.ErrorIs(t, d.Push("/app.js", nil), http.ErrNotSupported)
+	})
+}
+
+func TestBodyDumperPool(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rec)
+
+	d := newBodyDumper(rec, 2)
+	c.Response().Writer = d
+
+	_, err := c.Response().Write([]byte("data"))
+	require.NoError(t, err)
+	require.True(t, d.truncated)
+
+	releaseBodyDumper(c, d)
+	require.Equal(t, http.ResponseWriter(rec), c.Response().Writer)
+
+	// a dumper taken from the pool starts empty
+	d = newBodyDumper(httptest.NewRecorder(), 0)
+	require.Empty(t, d.GetResponse())
+	require.False(t, d.truncated)
+	require.Zero(t, d.size)
+	require.Nil(t, d.stream)
+}
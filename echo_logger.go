@@ -0,0 +1,188 @@
+package echozapmiddleware
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/log"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// echoLogger is an echo.Logger which writes to a zap logger.
+type echoLogger struct {
+	base   *zap.Logger
+	logger *zap.Logger
+	prefix string
+	level  log.Lvl
+}
+
+// NewEchoLogger returns an echo.Logger which writes to logger, e.g. to be used as echo.Echo.Logger.
+// The output is defined by logger, so SetOutput and SetHeader are no-ops.
+func NewEchoLogger(logger *zap.Logger) echo.Logger {
+	logger = logger.WithOptions(zap.AddCallerSkip(1))
+
+	return &echoLogger{
+		base:   logger,
+		logger: logger,
+		level:  echoLevel(logger.Level()),
+	}
+}
+
+func echoLevel(level zapcore.Level) log.Lvl {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return log.DEBUG
+	case level == zapcore.InfoLevel:
+		return log.INFO
+	case level == zapcore.WarnLevel:
+		return log.WARN
+	default:
+		return log.ERROR
+	}
+}
+
+// Output returns a writer which logs every write at Error level,
+// since echo uses it as the error log of the http.Server.
+func (l *echoLogger) Output() io.Writer {
+	return echoLoggerWriter{logger: l.logger}
+}
+
+func (l *echoLogger) SetOutput(io.Writer) {}
+
+func (l *echoLogger) Prefix() string {
+	return l.prefix
+}
+
+// SetPrefix sets the name of the logger.
+func (l *echoLogger) SetPrefix(p string) {
+	l.prefix = p
+	l.logger = l.base.Named(p)
+}
+
+func (l *echoLogger) Level() log.Lvl {
+	return l.level
+}
+
+func (l *echoLogger) SetLevel(v log.Lvl) {
+	l.level = v
+}
+
+func (l *echoLogger) SetHeader(string) {}
+
+func (l *echoLogger) log(level log.Lvl, zapLevel zapcore.Level, message string, fields ...zapcore.Field) {
+	// fatal and panic entries are logged regardless of the level, since they stop the execution
+	if level < l.level && zapLevel < zapcore.DPanicLevel {
+		return
+	}
+
+	if entry := l.logger.Check(zapLevel, message); entry != nil {
+		entry.Write(fields...)
+	}
+}
+
+func (l *echoLogger) Print(i ...any) {
+	l.log(log.INFO, zapcore.InfoLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Printf(format string, args ...any) {
+	l.log(log.INFO, zapcore.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *echoLogger) Printj(j log.JSON) {
+	l.log(log.INFO, zapcore.InfoLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Debug(i ...any) {
+	l.log(log.DEBUG, zapcore.DebugLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Debugf(format string, args ...any) {
+	l.log(log.DEBUG, zapcore.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *echoLogger) Debugj(j log.JSON) {
+	l.log(log.DEBUG, zapcore.DebugLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Info(i ...any) {
+	l.log(log.INFO, zapcore.InfoLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Infof(format string, args ...any) {
+	l.log(log.INFO, zapcore.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *echoLogger) Infoj(j log.JSON) {
+	l.log(log.INFO, zapcore.InfoLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Warn(i ...any) {
+	l.log(log.WARN, zapcore.WarnLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Warnf(format string, args ...any) {
+	l.log(log.WARN, zapcore.WarnLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *echoLogger) Warnj(j log.JSON) {
+	l.log(log.WARN, zapcore.WarnLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Error(i ...any) {
+	l.log(log.ERROR, zapcore.ErrorLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Errorf(format string, args ...any) {
+	l.log(log.ERROR, zapcore.ErrorLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *echoLogger) Errorj(j log.JSON) {
+	l.log(log.ERROR, zapcore.ErrorLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Fatal(i ...any) {
+	l.log(log.OFF, zapcore.FatalLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Fatalj(j log.JSON) {
+	l.log(log.OFF, zapcore.FatalLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Fatalf(format string, args ...any) {
+	l.log(log.OFF, zapcore.FatalLevel, fmt.Sprintf(format, args...))
+}
+
+func (l *echoLogger) Panic(i ...any) {
+	l.log(log.OFF, zapcore.PanicLevel, fmt.Sprint(i...))
+}
+
+func (l *echoLogger) Panicj(j log.JSON) {
+	l.log(log.OFF, zapcore.PanicLevel, "", jsonFields(j)...)
+}
+
+func (l *echoLogger) Panicf(format string, args ...any) {
+	l.log(log.OFF, zapcore.PanicLevel, fmt.Sprintf(format, args...))
+}
+
+func jsonFields(j log.JSON) []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(j))
+	for _, key := range sortedKeys(j) {
+		fields = append(fields, zap.Any(key, j[key]))
+	}
+
+	return fields
+}
+
+// echoLoggerWriter logs every write as an entry.
+type echoLoggerWriter struct {
+	logger *zap.Logger
+}
+
+func (w echoLoggerWriter) Write(p []byte) (int, error) {
+	w.logger.Error(strings.TrimSpace(string(p)))
+
+	return len(p), nil
+}
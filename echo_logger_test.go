@@ -0,0 +1,35 @@
+package echozapmiddleware
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/labstack/gommon/log"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestEchoLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := NewEchoLogger(zap.New(core))
+
+	require.Equal(t, log.DEBUG, logger.Level())
+
+	logger.SetPrefix("echo")
+	logger.Infof("listening on %s", ":8080")
+	logger.Warnj(log.JSON{"b": 2, "a": 1})
+	logger.SetLevel(log.ERROR)
+	logger.Info("dropped")
+	_, _ = fmt.Fprintln(logger.Output(), "http: TLS handshake error")
+
+	entries := logs.AllUntimed()
+	require.Len(t, entries, 3)
+	require.Equal(t, "listening on :8080", entries[0].Message)
+	require.Equal(t, "echo", entries[0].LoggerName)
+	require.Equal(t, zapcore.WarnLevel, entries[1].Level)
+	require.Equal(t, []zapcore.Field{zap.Int("a", 1), zap.Int("b", 2)}, entries[1].Context)
+	require.Equal(t, zapcore.ErrorLevel, entries[2].Level)
+	require.Equal(t, "http: TLS handshake error", entries[2].Message)
+}
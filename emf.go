@@ -0,0 +1,137 @@
+package echozapmiddleware
+
+import (
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultEMFNamespace is the CloudWatch namespace used when EMFConfig.Namespace is empty.
+const DefaultEMFNamespace = "EchoZap"
+
+// Metric and dimension names of the embedded metric format.
+const (
+	emfMetadataKey     = "_aws"
+	emfRouteDimension  = "Route"
+	emfMethodDimension = "Method"
+	emfLatencyMetric   = "Latency"
+)
+
+var emfStatusMetrics = [...]string{"Status2xx", "Status3xx", "Status4xx", "Status5xx"}
+
+// EMFConfig defines the config for AWS CloudWatch embedded metric format.
+type EMFConfig struct {
+	// Enabled embeds the latency and status class metrics in the log entry.
+	// CloudWatch counts only the written entries, so Validate rejects the settings dropping them:
+	// SuccessSampleRate, RouteSampleRates, MaxLogsPerSecond, DedupWindow and SkipStatusCodes.
+	// The logger has to enable the levels of all entries too, e.g. SuccessLevel
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// Namespace is the CloudWatch namespace of the metrics. If empty, DefaultEMFNamespace is used
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+}
+
+// emfMetadata is the "_aws" object describing the metrics of the log entry.
+type emfMetadata struct {
+	namespace string
+	timestamp time.Time
+}
+
+func (m emfMetadata) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt64("Timestamp", m.timestamp.UnixMilli())
+
+	return enc.AddArray("CloudWatchMetrics", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		return enc.AppendObject(zapcore.ObjectMarshalerFunc(m.marshalDirective))
+	}))
+}
+
+func (m emfMetadata) marshalDirective(enc zapcore.ObjectEncoder) error {
+	enc.AddString("Namespace", m.namespace)
+
+	err := enc.AddArray("Dimensions", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		return enc.AppendArray(zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+			enc.AppendString(emfRouteDimension)
+			enc.AppendString(emfMethodDimension)
+
+			return nil
+		}))
+	}))
+	if err != nil {
+		return err
+	}
+
+	return enc.AddArray("Metrics", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		if err := enc.AppendObject(emfMetric(emfLatencyMetric, "Milliseconds")); err != nil {
+			return err
+		}
+
+		for _, name := range emfStatusMetrics {
+			if err := enc.AppendObject(emfMetric(name, "Count")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+func emfMetric(name string, unit string) zapcore.ObjectMarshalerFunc {
+	return func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("Name", name)
+		enc.AddString("Unit", unit)
+
+		return nil
+	}
+}
+
+// addEMF returns the embedded metric format metadata, dimensions and metrics of the request.
+func addEMF(config ZapConfig, c echo.Context, latency time.Duration) []zapcore.Field {
+	if !config.EMF.Enabled {
+		return nil
+	}
+
+	namespace := config.EMF.Namespace
+	if namespace == "" {
+		namespace = DefaultEMFNamespace
+	}
+
+	fields := []zapcore.Field{
+		zap.Object(emfMetadataKey, emfMetadata{namespace: namespace, timestamp: time.Now()}),
+		zap.String(emfRouteDimension, c.Path()),
+		zap.String(emfMethodDimension, c.Request().Method),
+		zap.Float64(emfLatencyMetric, float64(latency)/float64(time.Millisecond)),
+	}
+
+	class := c.Response().Status/100 - 2
+	for i, name := range emfStatusMetrics {
+		count := 0
+		if i == class {
+			count = 1
+		}
+
+		fields = append(fields, zap.Int(name, count))
+	}
+
+	return fields
+}
+
+// dropsEntries reports whether config drops request entries, which would be missing from the EMF metrics.
+func (c EMFConfig) dropsEntries(config ZapConfig) bool {
+	if !c.Enabled {
+		return false
+	}
+
+	if config.SuccessSampleRate > 0 && config.SuccessSampleRate < 1 {
+		return true
+	}
+
+	for _, rate := range config.RouteSampleRates {
+		if rate < 1 {
+			return true
+		}
+	}
+
+	return config.MaxLogsPerSecond > 0 || config.DedupWindow > 0 || len(config.SkipStatusCodes) > 0
+}
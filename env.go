@@ -0,0 +1,136 @@
+package echozapmiddleware
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultEnvPrefix is the environment variables prefix used by ConfigFromEnv when prefix is empty.
+const DefaultEnvPrefix = "ECHOZAP"
+
+// ConfigFromEnv returns DefaultZapConfig overridden by environment variables:
+//
+//	<PREFIX>_HEADERS_DUMP       - AreHeadersDump (bool)
+//	<PREFIX>_HEADERS_TO_LOG     - HeadersToLog (comma separated)
+//	<PREFIX>_HEADERS_TO_EXCLUDE - HeadersToExclude (comma separated)
+//	<PREFIX>_BODY_DUMP          - IsBodyDump (bool)
+//	<PREFIX>_LIMIT_HTTP_BODY    - LimitHTTPBody (bool)
+//	<PREFIX>_LIMIT_SIZE         - LimitSize (int)
+//	<PREFIX>_SKIP_PATHS         - SkipPaths (comma separated)
+//	<PREFIX>_SKIP_STATUS_CODES  - SkipStatusCodes (comma separated)
+//
+// If prefix is empty, DefaultEnvPrefix will be used. The config is validated with ZapConfig.Validate.
+func ConfigFromEnv(prefix string) (ZapConfig, error) {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	config := DefaultZapConfig
+	env := envReader{prefix: prefix}
+
+	env.boolVar("HEADERS_DUMP", &config.AreHeadersDump)
+	env.listVar("HEADERS_TO_LOG", &config.HeadersToLog)
+	env.listVar("HEADERS_TO_EXCLUDE", &config.HeadersToExclude)
+	env.boolVar("BODY_DUMP", &config.IsBodyDump)
+	env.boolVar("LIMIT_HTTP_BODY", &config.LimitHTTPBody)
+	env.intVar("LIMIT_SIZE", &config.LimitSize)
+	env.listVar("SKIP_PATHS", &config.SkipPaths)
+	env.intListVar("SKIP_STATUS_CODES", &config.SkipStatusCodes)
+
+	if env.err != nil {
+		return ZapConfig{}, env.err
+	}
+
+	if err := config.Validate(); err != nil {
+		return ZapConfig{}, err
+	}
+
+	return config, nil
+}
+
+type envReader struct {
+	prefix string
+	err    error
+}
+
+func (e *envReader) lookup(name string) (string, string, bool) {
+	name = e.prefix + "_" + name
+	value, ok := os.LookupEnv(name)
+
+	return name, strings.TrimSpace(value), ok && e.err == nil
+}
+
+func (e *envReader) boolVar(name string, dst *bool) {
+	name, value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		e.err = fmt.Errorf("%s: %w", name, err)
+		return
+	}
+
+	*dst = v
+}
+
+func (e *envReader) intVar(name string, dst *int) {
+	name, value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		e.err = fmt.Errorf("%s: %w", name, err)
+		return
+	}
+
+	*dst = v
+}
+
+func (e *envReader) listVar(name string, dst *[]string) {
+	_, value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+
+	*dst = splitList(value)
+}
+
+func (e *envReader) intListVar(name string, dst *[]int) {
+	name, value, ok := e.lookup(name)
+	if !ok {
+		return
+	}
+
+	items := splitList(value)
+	result := make([]int, 0, len(items))
+
+	for _, item := range items {
+		v, err := strconv.Atoi(item)
+		if err != nil {
+			e.err = fmt.Errorf("%s: %w", name, err)
+			return
+		}
+
+		result = append(result, v)
+	}
+
+	*dst = result
+}
+
+func splitList(value string) []string {
+	var result []string
+
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
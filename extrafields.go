@@ -0,0 +1,292 @@
+package echozapmiddleware
+
+import (
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfNoneMatch = "If-None-Match"
+	headerAltSvc      = "Alt-Svc"
+	headerAltUsed     = "Alt-Used"
+	protoHTTP3        = "HTTP/3.0"
+)
+
+// ExtraFieldsConfig defines optional request fields of the log entry,
+// which otherwise would require dumping all headers.
+type ExtraFieldsConfig struct {
+	// UserAgent adds the User-Agent header
+	UserAgent bool `json:"user_agent" yaml:"user_agent"`
+
+	// Referer adds the Referer header
+	Referer bool `json:"referer" yaml:"referer"`
+
+	// Proto adds the protocol of the request, e.g. HTTP/1.1 or HTTP/2.0.
+	// HTTP/3 servers like quic-go report HTTP/3.0, and requests forwarded by a proxy terminating HTTP/3
+	// are detected by the Alt-Used header naming an h3 service advertised in Alt-Svc
+	Proto bool `json:"proto" yaml:"proto"`
+
+	// Route adds the route pattern of the request, e.g. /users/:id, to aggregate entries by endpoint
+	Route bool `json:"route" yaml:"route"`
+
+	// Params adds the path parameters of the route as an object, e.g. params.id
+	Params bool `json:"params" yaml:"params"`
+
+	// RedactParams defines path parameters which values are redacted, in the params and in every logged uri.
+	// Use "*" for the value of a wildcard
+	RedactParams []string `json:"redact_params,omitempty" yaml:"redact_params,omitempty"`
+
+	// Scheme adds the scheme of the request, taking X-Forwarded-Proto and similar headers into account
+	Scheme bool `json:"scheme" yaml:"scheme"`
+
+	// RemoteAddr adds the address and port of the connection, e.g. 192.0.2.1:1234, in addition to the real ip
+	RemoteAddr bool `json:"remote_addr" yaml:"remote_addr"`
+
+	// AuthUser adds the username of Basic credentials. The password is never logged
+	AuthUser bool `json:"auth_user" yaml:"auth_user"`
+
+	// AcceptLanguage adds the first language of the Accept-Language header, e.g. en-GB
+	AcceptLanguage bool `json:"accept_language" yaml:"accept_language"`
+
+	// Cache adds the ETag response header, the If-None-Match request header
+	// and, for conditional requests, whether the response was 304 Not Modified
+	Cache bool `json:"cache" yaml:"cache"`
+
+	// RateLimit adds the Retry-After response header and the limit, remaining and reset values
+	// of the X-RateLimit-* or RateLimit-* response headers, to make throttling visible
+	RateLimit bool `json:"rate_limit" yaml:"rate_limit"`
+}
+
+// extraField is an optional request field with a string value.
+type extraField struct {
+	enabled func(extra ExtraFieldsConfig) bool
+	name    func(names FieldNames) string
+	value   func(c echo.Context) string
+}
+
+// extraFields are the optional request fields with string values, in the order they are logged.
+var extraFields = []extraField{
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.UserAgent },
+		name:    func(names FieldNames) string { return names.UserAgent },
+		value:   func(c echo.Context) string { return c.Request().UserAgent() },
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.Referer },
+		name:    func(names FieldNames) string { return names.Referer },
+		value:   func(c echo.Context) string { return c.Request().Referer() },
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.Proto },
+		name:    func(names FieldNames) string { return names.Proto },
+		value:   requestProto,
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.Route },
+		name:    func(names FieldNames) string { return names.Route },
+		value:   echo.Context.Path,
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.Scheme },
+		name:    func(names FieldNames) string { return names.Scheme },
+		value:   echo.Context.Scheme,
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.RemoteAddr },
+		name:    func(names FieldNames) string { return names.RemoteAddr },
+		value:   func(c echo.Context) string { return c.Request().RemoteAddr },
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.AuthUser },
+		name:    func(names FieldNames) string { return names.AuthUser },
+		value: func(c echo.Context) string {
+			user, _, _ := c.Request().BasicAuth()
+			return user
+		},
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.AcceptLanguage },
+		name:    func(names FieldNames) string { return names.AcceptLanguage },
+		value:   acceptLanguage,
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.RateLimit },
+		name:    func(names FieldNames) string { return names.RetryAfter },
+		value:   func(c echo.Context) string { return c.Response().Header().Get(echo.HeaderRetryAfter) },
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.RateLimit },
+		name:    func(names FieldNames) string { return names.RateLimitLimit },
+		value:   rateLimitHeader("Limit"),
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.RateLimit },
+		name:    func(names FieldNames) string { return names.RateLimitRemaining },
+		value:   rateLimitHeader("Remaining"),
+	},
+	{
+		enabled: func(extra ExtraFieldsConfig) bool { return extra.RateLimit },
+		name:    func(names FieldNames) string { return names.RateLimitReset },
+		value:   rateLimitHeader("Reset"),
+	},
+}
+
+// appendExtraFields appends the enabled optional request fields. Empty values are omitted.
+func appendExtraFields(fields []zapcore.Field, config ZapConfig, c echo.Context) []zapcore.Field {
+	for _, field := range extraFields {
+		if field.enabled(config.ExtraFields) {
+			fields = appendNonEmpty(fields, field.name(config.FieldNames), field.value(c))
+		}
+	}
+
+	if config.ExtraFields.Params {
+		fields = appendParams(fields, config, c)
+	}
+
+	if config.ExtraFields.Cache {
+		fields = appendCacheFields(fields, config, c)
+	}
+
+	return fields
+}
+
+func appendNonEmpty(fields []zapcore.Field, name string, value string) []zapcore.Field {
+	if value == "" {
+		return fields
+	}
+
+	return append(fields, zap.String(name, value))
+}
+
+// pathParams marshals path parameters. The values are copied,
+// as echo reuses them for the next request of the context.
+type pathParams struct {
+	names  []string
+	values []string
+}
+
+func (p pathParams) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for i, name := range p.names {
+		enc.AddString(name, p.values[i])
+	}
+
+	return nil
+}
+
+func appendParams(fields []zapcore.Field, config ZapConfig, c echo.Context) []zapcore.Field {
+	names := c.ParamNames()
+	if len(names) == 0 {
+		return fields
+	}
+
+	values := slices.Clone(c.ParamValues())
+	for i, name := range names {
+		if slices.Contains(config.ExtraFields.RedactParams, name) {
+			values[i] = redactedValue
+		}
+	}
+
+	return append(fields, zap.Object(config.FieldNames.Params, pathParams{names: names, values: values}))
+}
+
+// acceptLanguage returns the first language of the Accept-Language header, e.g. "da" of "da, en-gb;q=0.8".
+func acceptLanguage(c echo.Context) string {
+	first, _, _ := strings.Cut(c.Request().Header.Get("Accept-Language"), ",")
+	first, _, _ = strings.Cut(first, ";")
+
+	return strings.TrimSpace(first)
+}
+
+// requestProto returns the protocol of the request. A request with Alt-Used sent over an older protocol
+// was received by a proxy over HTTP/3, unless the response advertises Alt-Svc without that h3 service.
+func requestProto(c echo.Context) string {
+	req := c.Request()
+
+	used := req.Header.Get(headerAltUsed)
+	if req.ProtoMajor >= 3 || used == "" {
+		return req.Proto
+	}
+
+	altSvc := c.Response().Header().Get(headerAltSvc)
+	if altSvc == "" || advertisesHTTP3(altSvc, req.Host, used) {
+		return protoHTTP3
+	}
+
+	return req.Proto
+}
+
+// advertisesHTTP3 reports whether altSvc, e.g. `h3=":443"; ma=86400, h2=":443"`, advertises
+// an h3 service at the authority used, e.g. "example.com:443". Services without a host are at origin.
+func advertisesHTTP3(altSvc, origin, used string) bool {
+	originHost, _ := splitAuthority(origin)
+	usedHost, usedPort := splitAuthority(used)
+
+	for _, service := range strings.Split(altSvc, ",") {
+		service, _, _ = strings.Cut(service, ";")
+
+		protocol, authority, ok := strings.Cut(strings.TrimSpace(service), "=")
+		if !ok || (protocol != "h3" && !strings.HasPrefix(protocol, "h3-")) {
+			continue
+		}
+
+		host, port := splitAuthority(strings.Trim(authority, `"`))
+		if host == "" {
+			host = originHost
+		}
+
+		if strings.EqualFold(host, usedHost) && port == usedPort {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitAuthority splits host[:port], defaulting to the https port.
+func splitAuthority(authority string) (string, string) {
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return strings.Trim(authority, "[]"), "443"
+	}
+
+	return host, port
+}
+
+// appendCacheFields appends the validators of the request and the response, and whether the cache of the client was hit.
+func appendCacheFields(fields []zapcore.Field, config ZapConfig, c echo.Context) []zapcore.Field {
+	names := config.FieldNames
+	req := c.Request()
+	ifNoneMatch := req.Header.Get(headerIfNoneMatch)
+
+	fields = appendNonEmpty(fields, names.RespETag, c.Response().Header().Get(headerETag))
+	fields = appendNonEmpty(fields, names.ReqIfNoneMatch, ifNoneMatch)
+
+	if ifNoneMatch != "" || req.Header.Get(echo.HeaderIfModifiedSince) != "" {
+		fields = append(fields, zap.Bool(names.CacheHit, c.Response().Status == http.StatusNotModified))
+	}
+
+	return fields
+}
+
+// rateLimitHeader returns a function getting the X-RateLimit-<name> response header,
+// or the RateLimit-<name> one of the IETF draft if the former is missing.
+func rateLimitHeader(name string) func(c echo.Context) string {
+	legacy := "X-RateLimit-" + name
+	draft := "RateLimit-" + name
+
+	return func(c echo.Context) string {
+		header := c.Response().Header()
+		if value := header.Get(legacy); value != "" {
+			return value
+		}
+
+		return header.Get(draft)
+	}
+}
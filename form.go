@@ -0,0 +1,104 @@
+package echozapmiddleware
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultSensitiveFormFields is the default list of form fields which values are redacted.
+var DefaultSensitiveFormFields = []string{
+	"password",
+	"passwd",
+	"secret",
+	"client_secret",
+	"token",
+	"access_token",
+	"refresh_token",
+	"api_key",
+	"apikey",
+}
+
+// formValues keeps form values in the order of appearance.
+type formValues struct {
+	config ZapConfig
+	names  []string
+	values map[string][]string
+}
+
+func newFormValues(config ZapConfig) *formValues {
+	return &formValues{
+		config: config,
+		values: make(map[string][]string),
+	}
+}
+
+func (f *formValues) add(name, value string) {
+	if _, ok := f.values[name]; !ok {
+		f.names = append(f.names, name)
+	}
+
+	f.values[name] = append(f.values[name], value)
+}
+
+func (f *formValues) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, name := range f.names {
+		redacted := slices.ContainsFunc(f.config.RedactFormFields, func(field string) bool {
+			return strings.EqualFold(field, name)
+		})
+
+		err := enc.AddArray(name, zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+			for _, value := range f.values[name] {
+				if redacted {
+					value = redactedValue
+				}
+
+				enc.AppendString(limitBody(f.config, value))
+			}
+
+			return nil
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formBodyField returns the request body field with parsed values
+// for application/x-www-form-urlencoded requests instead of the raw encoded string.
+func formBodyField(config ZapConfig, req *http.Request, body string) (zapcore.Field, bool) {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if err != nil || mediaType != echo.MIMEApplicationForm {
+		return zapcore.Field{}, false
+	}
+
+	values := newFormValues(config)
+
+	for _, pair := range strings.Split(body, "&") {
+		if pair == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(pair, "=")
+
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+
+		if unescaped, err := url.QueryUnescape(value); err == nil {
+			value = unescaped
+		}
+
+		values.add(name, value)
+	}
+
+	return zap.Object(config.FieldNames.ReqBody, values), true
+}
@@ -0,0 +1,144 @@
+package echozapmiddleware
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Field names recognized by Google Cloud Logging.
+const (
+	gcpHTTPRequestKey  = "httpRequest"
+	gcpTraceKey        = "logging.googleapis.com/trace"
+	gcpSpanIDKey       = "logging.googleapis.com/spanId"
+	gcpTraceSampledKey = "logging.googleapis.com/trace_sampled"
+)
+
+// GCPConfig defines the config for Google Cloud Logging formatted entries.
+type GCPConfig struct {
+	// Enabled adds the httpRequest payload and the trace fields to the log entry
+	Enabled bool `json:"enabled" yaml:"enabled"`
+
+	// ProjectID is used to build the trace resource name "projects/<ProjectID>/traces/<trace id>".
+	// If empty, the bare trace id is logged
+	ProjectID string `json:"project_id,omitempty" yaml:"project_id,omitempty"`
+}
+
+// NewGCPEncoderConfig returns an encoder config which writes levels
+// as Google Cloud Logging severities, e.g. for zap.NewProductionConfig().EncoderConfig.
+func NewGCPEncoderConfig() zapcore.EncoderConfig {
+	config := zap.NewProductionEncoderConfig()
+	config.LevelKey = "severity"
+	config.MessageKey = "message"
+	config.TimeKey = "timestamp"
+	config.EncodeLevel = gcpLevelEncoder
+	config.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+
+	return config
+}
+
+func gcpLevelEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+	switch level {
+	case zapcore.DebugLevel:
+		enc.AppendString("DEBUG")
+	case zapcore.InfoLevel:
+		enc.AppendString("INFO")
+	case zapcore.WarnLevel:
+		enc.AppendString("WARNING")
+	case zapcore.ErrorLevel:
+		enc.AppendString("ERROR")
+	case zapcore.DPanicLevel:
+		enc.AppendString("CRITICAL")
+	case zapcore.PanicLevel:
+		enc.AppendString("ALERT")
+	case zapcore.FatalLevel:
+		enc.AppendString("EMERGENCY")
+	default:
+		enc.AppendString("DEFAULT")
+	}
+}
+
+// gcpHTTPRequest is the HttpRequest payload of Google Cloud Logging.
+// The values are copied from the request, as the entry may be encoded after echo has reused its context.
+type gcpHTTPRequest struct {
+	method       string
+	url          string
+	status       int
+	responseSize int64
+	requestSize  int64
+	userAgent    string
+	remoteIP     string
+	referer      string
+	protocol     string
+	latency      time.Duration
+}
+
+func newGCPHTTPRequest(config ZapConfig, c echo.Context, latency time.Duration) gcpHTTPRequest {
+	req := c.Request()
+	res := c.Response()
+
+	return gcpHTTPRequest{
+		method:       req.Method,
+		url:          requestURI(config, c),
+		status:       res.Status,
+		responseSize: res.Size,
+		requestSize:  req.ContentLength,
+		userAgent:    req.UserAgent(),
+		remoteIP:     c.RealIP(),
+		referer:      req.Referer(),
+		protocol:     req.Proto,
+		latency:      latency,
+	}
+}
+
+func (r gcpHTTPRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("requestMethod", r.method)
+	enc.AddString("requestUrl", r.url)
+	enc.AddInt("status", r.status)
+	enc.AddString("responseSize", strconv.FormatInt(r.responseSize, 10))
+	enc.AddString("userAgent", r.userAgent)
+	enc.AddString("remoteIp", r.remoteIP)
+	enc.AddString("protocol", r.protocol)
+	enc.AddString("latency", fmt.Sprintf("%.9fs", r.latency.Seconds()))
+
+	if r.requestSize > 0 {
+		enc.AddString("requestSize", strconv.FormatInt(r.requestSize, 10))
+	}
+
+	if r.referer != "" {
+		enc.AddString("referer", r.referer)
+	}
+
+	return nil
+}
+
+// addGCP returns the httpRequest payload and the trace fields of Google Cloud Logging.
+func addGCP(config ZapConfig, c echo.Context, latency time.Duration) []zapcore.Field {
+	if !config.GCP.Enabled {
+		return nil
+	}
+
+	fields := []zapcore.Field{
+		zap.Object(gcpHTTPRequestKey, newGCPHTTPRequest(config, c, latency)),
+	}
+
+	sc, _ := requestSpanContext(c.Request())
+	if !sc.IsValid() {
+		return fields
+	}
+
+	traceName := sc.TraceID().String()
+	if config.GCP.ProjectID != "" {
+		traceName = "projects/" + config.GCP.ProjectID + "/traces/" + traceName
+	}
+
+	return append(fields,
+		zap.String(gcpTraceKey, traceName),
+		zap.String(gcpSpanIDKey, sc.SpanID().String()),
+		zap.Bool(gcpTraceSampledKey, sc.IsSampled()),
+	)
+}
@@ -0,0 +1,60 @@
+package echozapmiddleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestGCPEncoderConfig(t *testing.T) {
+	var buf bytes.Buffer
+
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(NewGCPEncoderConfig()), zapcore.AddSync(&buf), zapcore.DebugLevel)
+	logger := zap.New(core)
+
+	logger.Warn("Client error")
+	logger.Error("Server error")
+
+	require.Contains(t, buf.String(), `"severity":"WARNING"`)
+	require.Contains(t, buf.String(), `"severity":"ERROR"`)
+	require.Contains(t, buf.String(), `"message":"Client error"`)
+}
+
+// TestGCPWithAsync checks with -race that the httpRequest payload doesn't read the reused echo context.
+func TestGCPWithAsync(t *testing.T) {
+	const requests = 50
+
+	written := make(chan struct{}, requests)
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(NewGCPEncoderConfig()), zapcore.AddSync(io.Discard), zapcore.DebugLevel)
+
+	holder := NewConfigHolder(ZapConfig{
+		GCP:      GCPConfig{Enabled: true},
+		Async:    AsyncConfig{Enabled: true},
+		Observer: func(LogEntry) { written <- struct{}{} },
+	})
+	defer holder.Close()
+
+	e := echo.New()
+	e.Use(MiddlewareWithConfigHolder(zap.New(core), holder))
+	e.GET("/ping", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	for i := range requests {
+		r := httptest.NewRequest(http.MethodGet, "/ping?i="+strconv.Itoa(i), nil)
+		r.Header.Set("Referer", "https://example.com/"+strconv.Itoa(i))
+		e.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	for range requests {
+		<-written
+	}
+}
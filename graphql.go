@@ -0,0 +1,217 @@
+package echozapmiddleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// maxGraphQLBodyBytes is the number of request body bytes captured to parse a GraphQL request.
+// The operation of a larger request is not logged.
+const maxGraphQLBodyBytes = 1 << 20
+
+// GraphQL operation types.
+const (
+	graphQLQuery        = "query"
+	graphQLMutation     = "mutation"
+	graphQLSubscription = "subscription"
+)
+
+// GraphQLConfig defines the config for logging GraphQL operations.
+type GraphQLConfig struct {
+	// Path defines the path of the GraphQL endpoint, e.g. /graphql. GraphQL operations are not logged if empty
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// HashQuery adds the SHA-256 hash of the query document, to tell apart anonymous operations
+	HashQuery bool `json:"hash_query" yaml:"hash_query"`
+}
+
+func (g GraphQLConfig) matches(req *http.Request) bool {
+	return g.Path != "" && req.URL != nil && req.URL.Path == g.Path
+}
+
+// graphQLParams are the parameters of a GraphQL request.
+type graphQLParams struct {
+	Query         string `json:"query"`
+	OperationName string `json:"operationName"`
+}
+
+// captureGraphQLBody returns the body of a GraphQL POST request. The body captured for dumping is reused if it's whole.
+func captureGraphQLBody(config ZapConfig, req *http.Request, entry requestLog) []byte {
+	if !config.GraphQL.matches(req) || entry.upgraded || req.Method == http.MethodGet {
+		return nil
+	}
+
+	if entry.reqBody != nil && captureLimit(config) < 0 {
+		return entry.reqBody
+	}
+
+	return captureRequestBody(req, maxGraphQLBodyBytes)
+}
+
+// addGraphQL returns the name and the type of the GraphQL operation of the request,
+// read from the query string of GET requests or from the JSON body otherwise.
+func addGraphQL(config ZapConfig, req *http.Request, body []byte) []zapcore.Field {
+	if !config.GraphQL.matches(req) {
+		return nil
+	}
+
+	var params graphQLParams
+
+	if req.Method == http.MethodGet {
+		query := req.URL.Query()
+		params.Query = query.Get("query")
+		params.OperationName = query.Get("operationName")
+	} else if err := json.Unmarshal(body, &params); err != nil {
+		return nil
+	}
+
+	opType, opName := graphQLOperation(params.Query, params.OperationName)
+	names := config.FieldNames
+
+	var fields []zapcore.Field
+
+	fields = appendNonEmpty(fields, names.GraphQLOperationName, opName)
+	fields = appendNonEmpty(fields, names.GraphQLOperationType, opType)
+
+	if config.GraphQL.HashQuery && params.Query != "" {
+		sum := sha256.Sum256([]byte(params.Query))
+		fields = appendNonEmpty(fields, names.GraphQLQueryHash, hex.EncodeToString(sum[:]))
+	}
+
+	return fields
+}
+
+// graphQLOperation returns the type and the name of the operation to execute,
+// i.e. the one named operationName, or the first one of the document if operationName is empty.
+// The type is empty if the operation is not found.
+func graphQLOperation(document string, operationName string) (string, string) {
+	scanner := graphQLScanner{document: document}
+	inFragment := false
+
+	for token := scanner.next(); token != ""; token = scanner.next() {
+		switch token {
+		case "fragment":
+			inFragment = true
+		case "{":
+			if !inFragment && operationName == "" {
+				return graphQLQuery, "" // query shorthand
+			}
+
+			inFragment = false
+		case graphQLQuery, graphQLMutation, graphQLSubscription:
+			name := scanner.name()
+			if operationName == "" || operationName == name {
+				return token, name
+			}
+		}
+	}
+
+	return "", operationName
+}
+
+// graphQLScanner reads the top level tokens of a GraphQL document.
+type graphQLScanner struct {
+	document string
+	pos      int
+}
+
+// next returns the next top level name or bracket. The content of brackets is skipped.
+func (s *graphQLScanner) next() string {
+	token := s.token()
+	if token != "{" && token != "(" && token != "[" {
+		return token
+	}
+
+	for depth := 1; depth > 0; {
+		switch s.token() {
+		case "{", "(", "[":
+			depth++
+		case "}", ")", "]":
+			depth--
+		case "":
+			return token
+		}
+	}
+
+	return token
+}
+
+// name returns the next token if it's a name, e.g. of an operation, or an empty string otherwise.
+func (s *graphQLScanner) name() string {
+	pos := s.pos
+
+	token := s.token()
+	if token == "" || !isGraphQLNameChar(token[0]) {
+		s.pos = pos
+		return ""
+	}
+
+	return token
+}
+
+// token returns the next name or bracket. Strings are returned as a quote.
+// Comments, whitespace and other punctuators are skipped.
+func (s *graphQLScanner) token() string {
+	doc := s.document
+
+	for s.pos < len(doc) {
+		start := s.pos
+		ch := doc[start]
+
+		switch {
+		case ch == '#':
+			s.pos = len(doc)
+			if end := strings.IndexByte(doc[start:], '\n'); end >= 0 {
+				s.pos = start + end
+			}
+		case ch == '"':
+			s.pos = skipGraphQLString(doc, start)
+			return `"`
+		case isGraphQLNameChar(ch):
+			for s.pos < len(doc) && isGraphQLNameChar(doc[s.pos]) {
+				s.pos++
+			}
+
+			return doc[start:s.pos]
+		case strings.IndexByte("{}()[]", ch) >= 0:
+			s.pos++
+			return doc[start:s.pos]
+		default:
+			s.pos++
+		}
+	}
+
+	return ""
+}
+
+// skipGraphQLString returns the index following the string or the block string starting at i.
+func skipGraphQLString(document string, i int) int {
+	if strings.HasPrefix(document[i:], `"""`) {
+		end := strings.Index(document[i+3:], `"""`)
+		if end < 0 {
+			return len(document)
+		}
+
+		return i + 3 + end + 3
+	}
+
+	for i++; i < len(document); i++ {
+		switch document[i] {
+		case '\\':
+			i++
+		case '"', '\n':
+			return i + 1
+		}
+	}
+
+	return i
+}
+
+func isGraphQLNameChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,41 @@
+package echozapmiddleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGraphQLOperation(t *testing.T) {
+	tests := []struct {
+		name          string
+		document      string
+		operationName string
+		opType        string
+		opName        string
+	}{
+		{name: "shorthand", document: "{ user(id: 1) { name } }", opType: "query"},
+		{name: "anonymous", document: "mutation { logout }", opType: "mutation"},
+		{name: "named", document: "query GetUser($id: ID!) { user(id: $id) { name } }", opType: "query", opName: "GetUser"},
+		{name: "first", document: "subscription OnEvent { event } query Q { q }", opType: "subscription", opName: "OnEvent"},
+		{
+			name:          "selected",
+			document:      "query A { a(s: \"mutation B {\") } # mutation B\nmutation B { b }",
+			operationName: "B",
+			opType:        "mutation",
+			opName:        "B",
+		},
+		{name: "after fragment", document: "fragment F on User { name } { me { ...F } }", opType: "query"},
+		{name: "not found", document: "query A { a }", operationName: "B", opName: "B"},
+		{name: "persisted", operationName: "GetUser", opName: "GetUser"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opType, opName := graphQLOperation(tt.document, tt.operationName)
+			require.Equal(t, tt.opType, opType)
+			require.Equal(t, tt.opName, opName)
+		})
+	}
+}
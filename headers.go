@@ -0,0 +1,102 @@
+package echozapmiddleware
+
+import (
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const redactedValue = "[redacted]"
+
+// headerSet is a set of canonical header names.
+type headerSet map[string]struct{}
+
+func newHeaderSet(names []string) headerSet {
+	if len(names) == 0 {
+		return nil
+	}
+
+	set := make(headerSet, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(name)] = struct{}{}
+	}
+
+	return set
+}
+
+func (s headerSet) contains(name string) bool {
+	_, ok := s[http.CanonicalHeaderKey(name)]
+	return ok
+}
+
+func filterHeaders(config ZapConfig, headers http.Header) http.Header {
+	result := make(http.Header, len(headers))
+
+	for name, values := range headers {
+		if config.headersToLog != nil && !config.headersToLog.contains(name) {
+			continue
+		}
+
+		if config.headersToExclude.contains(name) {
+			continue
+		}
+
+		if config.sensitiveHeaders.contains(name) {
+			values = redactValues(values)
+		}
+
+		if config.HeaderMasker != nil {
+			values = config.HeaderMasker(name, values)
+			if len(values) == 0 {
+				continue
+			}
+		}
+
+		result[name] = values
+	}
+
+	return result
+}
+
+func addHeaders(config ZapConfig, reqHeaders http.Header, resHeaders http.Header) []zapcore.Field {
+	if !config.AreHeadersDump {
+		return nil
+	}
+
+	if config.FlatHeaders {
+		fields := flatHeaders(config.FieldNames.ReqHeader, filterHeaders(config, reqHeaders))
+		return append(fields, flatHeaders(config.FieldNames.RespHeader, filterHeaders(config, resHeaders))...)
+	}
+
+	return []zapcore.Field{
+		zap.Any(config.FieldNames.ReqHeaders, filterHeaders(config, reqHeaders)),
+		zap.Any(config.FieldNames.RespHeaders, filterHeaders(config, resHeaders)),
+	}
+}
+
+func flatHeaders(prefix string, headers http.Header) []zapcore.Field {
+	names := sortedKeys(headers)
+
+	fields := make([]zapcore.Field, 0, len(names))
+	for _, name := range names {
+		fields = append(fields, zap.String(prefix+"."+flatHeaderName(name), strings.Join(headers[name], ", ")))
+	}
+
+	return fields
+}
+
+// flatHeaderName converts header name to field name, e.g. User-Agent to user_agent.
+func flatHeaderName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+func redactValues(values []string) []string {
+	redacted := make([]string, len(values))
+	for i := range values {
+		redacted[i] = redactedValue
+	}
+
+	return redacted
+}
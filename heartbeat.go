@@ -0,0 +1,58 @@
+package echozapmiddleware
+
+import (
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const heartbeatMessage = "Request in progress"
+
+// startHeartbeat logs an entry every LongRunningInterval until the returned function is called.
+func startHeartbeat(l *lazyLogger, config ZapConfig, c echo.Context, start time.Time) func() {
+	if config.LongRunningInterval <= 0 {
+		return func() {}
+	}
+
+	logger := l.get()
+
+	// the context must not be used by the goroutine, so the fields are collected in advance
+	req := c.Request()
+	names := config.FieldNames
+	fields := []zapcore.Field{
+		zap.String(names.RequestID, resolveRequestID(config, c)),
+		zap.String(names.Method, req.Method),
+	}
+
+	fields = appendURI(fields, config, c)
+	fields = appendCorrelationID(fields, config, c)
+
+	done := make(chan struct{})
+	ticker := time.NewTicker(config.LongRunningInterval)
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				logger.Info(heartbeatMessage, append(fields, zap.Duration(names.Elapsed, now.Sub(start)))...)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -2,58 +2,146 @@ package echozapmiddleware
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 	"unicode/utf8"
 
-	"github.com/adlandh/response-dumper"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-func prepareReqAndResp(c echo.Context, config ZapConfig) (*response.Dumper, []byte) {
-	var respDumper *response.Dumper
+func prepareReqAndResp(c echo.Context, config ZapConfig, entry *requestLog) {
+	req := c.Request()
 
-	var reqBody []byte
+	if !config.IsBodyDump {
+		return
+	}
 
-	req := c.Request()
+	if req.Body != nil && req.Body != http.NoBody {
+		entry.reqArchive = newArchiveSink(req.Context(), config).
+			forMediaType(parseMediaType(req.Header.Get(echo.HeaderContentType)))
+		entry.reqRead = &countingReader{ReadCloser: req.Body, archive: entry.reqArchive}
+		req.Body = entry.reqRead
+	}
 
-	if config.IsBodyDump {
-		if req.Body != nil {
-			var err error
+	entry.reqBody = captureRequestBody(req, captureLimit(config))
 
-			reqBody, err = io.ReadAll(req.Body)
-			if err == nil {
-				_ = req.Body.Close()
-				req.Body = io.NopCloser(bytes.NewBuffer(reqBody)) // reset original request body
-			}
-		}
+	entry.respDumper = newBodyDumper(c.Response().Writer, config.MaxCapturedResponseBytes)
+	entry.respDumper.archive = newArchiveSink(req.Context(), config)
+	c.Response().Writer = entry.respDumper
+}
+
+// countingReader counts the bytes read from the request body, including the captured ones,
+// and tees them to archive.
+type countingReader struct {
+	io.ReadCloser
+	read    int64
+	archive *archiveSink
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.read += int64(n)
+
+	r.archive.write(p[:n])
 
-		respDumper = response.NewDumper(c.Response().Writer)
-		c.Response().Writer = respDumper
+	if errors.Is(err, io.EOF) {
+		r.archive.end()
 	}
 
-	return respDumper, reqBody
+	return n, err //nolint:wrapcheck
 }
 
-func limitString(str string, size int) string {
-	if len(str) <= size {
-		return str
+// bytesRead returns the number of bytes read from the request body.
+func (r *countingReader) bytesRead() int64 {
+	if r == nil {
+		return 0
+	}
+
+	return r.read
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// maxProcessedBodyBytes is the number of request body bytes captured when bodies are redacted or decoded
+// before they are truncated. A larger body can't be parsed, so it's excluded when redacted.
+const maxProcessedBodyBytes = 1 << 20
+
+// captureLimit returns the number of request body bytes to capture, or -1 to capture the whole body.
+// One extra byte is captured, so the logged body can be marked as truncated.
+func captureLimit(config ZapConfig) int64 {
+	if !config.LimitHTTPBody || config.LimitSize <= 0 {
+		return -1
+	}
+
+	limit := int64(config.LimitSize) + 1
+	if isBodyProcessed(config) {
+		limit = max(limit, maxProcessedBodyBytes)
 	}
 
-	strBytes := []byte(str)
+	return limit
+}
+
+// isBodyProcessed reports whether bodies are redacted, decoded or formatted, which needs them whole rather than truncated.
+func isBodyProcessed(config ZapConfig) bool {
+	return config.BodyDecoder != nil || config.BodyFormat != BodyFormatAsIs || len(config.redactJSONPaths) > 0 ||
+		len(config.RedactXMLElements) > 0 || len(config.BodyRedactors) > 0
+}
+
+// captureRequestBody reads up to limit bytes of the request body and puts them back in front of the rest of it,
+// so the handler still streams the whole body while only the captured part is kept in memory.
+func captureRequestBody(req *http.Request, limit int64) []byte {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	var reader io.Reader = req.Body
+	if limit >= 0 {
+		reader = io.LimitReader(req.Body, limit)
+	}
 
-	if len(strBytes) <= size {
+	captured, err := io.ReadAll(reader)
+
+	rest := io.Reader(req.Body)
+	if err != nil {
+		rest = errReader{err: err}
+	}
+
+	req.Body = readCloser{
+		Reader: io.MultiReader(bytes.NewReader(captured), rest),
+		Closer: req.Body,
+	}
+
+	return captured
+}
+
+func limitString(str string, size int) string {
+	if len(str) <= size {
 		return str
 	}
 
-	validBytes := strBytes[:size]
-	for !utf8.Valid(validBytes) {
-		validBytes = validBytes[:len(validBytes)-1]
+	result := str[:size]
+	for !utf8.ValidString(result) {
+		result = result[:len(result)-1]
 	}
 
-	return string(validBytes)
+	return result
 }
 
 func limitStringWithDots(str string, size int) string {
@@ -70,11 +158,102 @@ func limitStringWithDots(str string, size int) string {
 }
 
 func limitBody(config ZapConfig, str string) string {
-	if !config.LimitHTTPBody {
-		return str
+	result := str
+	if config.LimitHTTPBody {
+		result = limitStringWithDots(str, config.LimitSize)
+	}
+
+	recordBodyStats(len(result), result != str)
+
+	return result
+}
+
+func limitBytes(b []byte, size int) []byte {
+	if len(b) <= size {
+		return b
+	}
+
+	result := b[:size]
+	for !utf8.Valid(result) {
+		result = result[:len(result)-1]
+	}
+
+	return result
+}
+
+func limitBytesWithDots(b []byte, size int) []byte {
+	if size <= 10 {
+		return limitBytes(b, size)
+	}
+
+	result := limitBytes(b, size-3)
+	if len(result) == len(b) {
+		return b
+	}
+
+	// cap the capacity, so appending doesn't overwrite the captured body
+	return append(result[:len(result):len(result)], "..."...)
+}
+
+// limitBodyBytes is limitBody for a body which isn't converted to a string.
+func limitBodyBytes(config ZapConfig, body []byte) []byte {
+	result := body
+	if config.LimitHTTPBody {
+		result = limitBytesWithDots(body, config.LimitSize)
+	}
+
+	recordBodyStats(len(result), len(result) != len(body))
+
+	return result
+}
+
+// standardMethods are the methods defined by RFC 9110 and RFC 5789.
+var standardMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// otherMethod replaces non-standard methods, which clients may choose freely.
+const otherMethod = "other"
+
+// normalizeMethod returns method if it's standard, or otherMethod.
+func normalizeMethod(method string) string {
+	if slices.Contains(standardMethods, method) {
+		return method
+	}
+
+	return otherMethod
+}
+
+// isUpgradeRequest reports whether the request asks for a protocol upgrade, e.g. to websocket.
+func isUpgradeRequest(req *http.Request) bool {
+	if req.Header.Get(echo.HeaderUpgrade) == "" {
+		return false
+	}
+
+	for _, value := range req.Header.Values(echo.HeaderConnection) {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
 	}
 
-	return limitStringWithDots(str, config.LimitSize)
+	return false
+}
+
+// resolveRequestID returns the request id, falling back to the trace id if TraceIDAsRequestID is set.
+func resolveRequestID(config ZapConfig, c echo.Context) string {
+	id := getRequestID(c)
+	if id != "" || !config.TraceIDAsRequestID {
+		return id
+	}
+
+	if sc, _ := requestSpanContext(c.Request()); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+
+	return ""
 }
 
 func getRequestID(ctx echo.Context) string {
@@ -87,52 +266,264 @@ func getRequestID(ctx echo.Context) string {
 	return requestID
 }
 
-func logit(status int, logger *zap.Logger, fields []zapcore.Field) {
+const requestStartMessage = "Request started"
+
+// DefaultLevelFunc logs 5xx responses at Error level, 4xx at Warn level and others at Info level.
+func DefaultLevelFunc(status int, _ error, _ time.Duration) zapcore.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return zapcore.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
+
+// successLevelFunc returns DefaultLevelFunc logging 2xx and 3xx responses at level.
+func successLevelFunc(level zapcore.Level) LevelFunc {
+	if level == zapcore.InfoLevel {
+		return DefaultLevelFunc
+	}
+
+	return func(status int, err error, latency time.Duration) zapcore.Level {
+		if status < http.StatusBadRequest {
+			return level
+		}
+
+		return DefaultLevelFunc(status, err, latency)
+	}
+}
+
+func defaultMessage(status int, slow bool) string {
 	switch {
 	case status >= 500:
-		logger.Error("Server error", fields...)
+		return "Server error"
 	case status >= 400:
-		logger.Warn("Client error", fields...)
+		return "Client error"
+	case slow:
+		return "Slow request"
 	case status >= 300:
-		logger.Info("Redirection", fields...)
+		return "Redirection"
 	default:
-		logger.Info("Success", fields...)
+		return "Success"
 	}
 }
 
-func addHeaders(config ZapConfig, reqHeaders http.Header, resHeaders http.Header) []zapcore.Field {
-	if !config.AreHeadersDump {
-		return nil
+// logit writes the entry and reports whether it was enabled.
+func logit(logger entryLogger, level zapcore.Level, message string, fields []zapcore.Field) bool {
+	entry := logger.logger.Check(level, message)
+	if entry == nil {
+		return false
 	}
 
-	return []zapcore.Field{
-		zap.Any("req.headers", reqHeaders),
-		zap.Any("resp.headers", resHeaders),
+	if logger.ctx != nil {
+		fields = append(fields, contextField(logger.ctx))
 	}
+
+	entry.Write(fields...)
+
+	return true
 }
 
-func addBody(config ZapConfig, c echo.Context, reqBody string, respDumper *response.Dumper) []zapcore.Field {
-	if !config.IsBodyDump {
+// addError returns the handler error. Code, message and internal error of echo.HTTPError are logged separately.
+func addError(config ZapConfig, err error) []zapcore.Field {
+	if err == nil {
 		return nil
 	}
 
-	skipReq, skipResp := config.BodySkipper(c)
-
 	var fields []zapcore.Field
 
-	body := limitBody(config, reqBody)
-	if len(body) > 0 && skipReq {
-		body = "[excluded]"
-	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		fields = append(fields,
+			zap.Int(config.FieldNames.ErrorCode, httpErr.Code),
+			zap.Any(config.FieldNames.ErrorMessage, httpErr.Message),
+		)
 
-	fields = append(fields, zap.String("req.body", body))
+		if httpErr.Internal != nil {
+			fields = append(fields, zap.NamedError(config.FieldNames.ErrorInternal, httpErr.Internal))
+		}
+	} else {
+		if code, ok := errorStatus(err); ok {
+			fields = append(fields, zap.Int(config.FieldNames.ErrorCode, code))
+		}
 
-	body = limitBody(config, respDumper.GetResponse())
-	if len(body) > 0 && skipResp {
-		body = "[excluded]"
+		fields = append(fields, zap.NamedError(config.FieldNames.Error, err))
 	}
 
-	fields = append(fields, zap.String("resp.body", body))
+	if config.ErrorFieldsFunc != nil {
+		fields = append(fields, config.ErrorFieldsFunc(err)...)
+	}
 
 	return fields
 }
+
+// isServerError reports whether the response is 5xx or the handler returned an error without a status.
+func isServerError(status int, err error) bool {
+	if status >= http.StatusInternalServerError {
+		return true
+	}
+
+	_, ok := errorStatus(err)
+
+	return err != nil && !ok
+}
+
+// statusCoder is implemented by errors carrying a response status, e.g. the HTTP errors of Echo v5.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// errorStatus returns the response status carried by err, if it's echo.HTTPError or a statusCoder.
+func errorStatus(err error) (int, bool) {
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code, true
+	}
+
+	var coder statusCoder
+	if errors.As(err, &coder) && coder.StatusCode() != 0 {
+		return coder.StatusCode(), true
+	}
+
+	return 0, false
+}
+
+// logRequestStart logs the arrival of a request.
+func logRequestStart(logger *zap.Logger, config ZapConfig, c echo.Context) {
+	req := c.Request()
+	names := config.FieldNames
+	fields := []zapcore.Field{
+		zap.String(names.RequestID, resolveRequestID(config, c)),
+		zap.String(names.Method, req.Method),
+	}
+
+	fields = appendURI(fields, config, c)
+	fields = append(fields,
+		zap.String(names.Host, req.Host),
+		zap.String(names.RemoteIP, c.RealIP()),
+	)
+	fields = appendCorrelationID(fields, config, c)
+
+	logger.Info(requestStartMessage, append(fields, addTrace(config, c)...)...)
+}
+
+func appendLogFields(fields []zapcore.Field, config ZapConfig, c echo.Context, entry requestLog) []zapcore.Field {
+	req := c.Request()
+	names := config.FieldNames
+
+	fields = append(fields, zap.Int(names.Status, c.Response().Status))
+	fields = appendLatency(fields, config, entry.latency)
+
+	fields = append(fields,
+		zap.String(names.RequestID, resolveRequestID(config, c)),
+		zap.String(names.Method, req.Method),
+	)
+	fields = appendURI(fields, config, c)
+
+	return append(fields,
+		zap.String(names.Host, req.Host),
+		zap.String(names.RemoteIP, c.RealIP()),
+		zap.Int64(names.BytesIn, max(req.ContentLength, 0)),
+		zap.Int64(names.BytesOut, c.Response().Size),
+	)
+}
+
+// withDefaults returns n with empty names taken from def.
+func (n FieldNames) withDefaults(def FieldNames) FieldNames {
+	pairs := []struct {
+		name *string
+		def  string
+	}{
+		{&n.Status, def.Status},
+		{&n.Latency, def.Latency},
+		{&n.RequestID, def.RequestID},
+		{&n.Method, def.Method},
+		{&n.URI, def.URI},
+		{&n.URLQuery, def.URLQuery},
+		{&n.Host, def.Host},
+		{&n.RemoteIP, def.RemoteIP},
+		{&n.BytesIn, def.BytesIn},
+		{&n.BytesOut, def.BytesOut},
+		{&n.Upgraded, def.Upgraded},
+		{&n.TraceID, def.TraceID},
+		{&n.SpanID, def.SpanID},
+		{&n.ParentSpanID, def.ParentSpanID},
+		{&n.Suppressed, def.Suppressed},
+		{&n.RepeatCount, def.RepeatCount},
+		{&n.Slow, def.Slow},
+		{&n.Elapsed, def.Elapsed},
+		{&n.Error, def.Error},
+		{&n.ErrorCode, def.ErrorCode},
+		{&n.ErrorMessage, def.ErrorMessage},
+		{&n.ErrorInternal, def.ErrorInternal},
+		{&n.Stack, def.Stack},
+		{&n.DatadogTraceID, def.DatadogTraceID},
+		{&n.DatadogSpanID, def.DatadogSpanID},
+		{&n.LatencyHuman, def.LatencyHuman},
+		{&n.UserAgent, def.UserAgent},
+		{&n.Referer, def.Referer},
+		{&n.Proto, def.Proto},
+		{&n.Route, def.Route},
+		{&n.Params, def.Params},
+		{&n.Query, def.Query},
+		{&n.BytesRead, def.BytesRead},
+		{&n.Scheme, def.Scheme},
+		{&n.RemoteAddr, def.RemoteAddr},
+		{&n.AuthUser, def.AuthUser},
+		{&n.SessionID, def.SessionID},
+		{&n.UABrowser, def.UABrowser},
+		{&n.UAOS, def.UAOS},
+		{&n.UADevice, def.UADevice},
+		{&n.UABot, def.UABot},
+		{&n.ReqHeaders, def.ReqHeaders},
+		{&n.RespHeaders, def.RespHeaders},
+		{&n.ReqHeader, def.ReqHeader},
+		{&n.ReqCookies, def.ReqCookies},
+		{&n.RespHeader, def.RespHeader},
+		{&n.ReqBody, def.ReqBody},
+		{&n.RespBody, def.RespBody},
+		{&n.ReqBodyEncoding, def.ReqBodyEncoding},
+		{&n.RespBodyEncoding, def.RespBodyEncoding},
+		{&n.RespBodyTruncated, def.RespBodyTruncated},
+		{&n.RespBodySize, def.RespBodySize},
+		{&n.ReqBodyRef, def.ReqBodyRef},
+		{&n.RespBodyRef, def.RespBodyRef},
+		{&n.RespEvents, def.RespEvents},
+		{&n.RespStreamDuration, def.RespStreamDuration},
+		{&n.AcceptLanguage, def.AcceptLanguage},
+		{&n.RespETag, def.RespETag},
+		{&n.ReqIfNoneMatch, def.ReqIfNoneMatch},
+		{&n.CacheHit, def.CacheHit},
+		{&n.RetryAfter, def.RetryAfter},
+		{&n.RateLimitLimit, def.RateLimitLimit},
+		{&n.RateLimitRemaining, def.RateLimitRemaining},
+		{&n.RateLimitReset, def.RateLimitReset},
+		{&n.GraphQLOperationName, def.GraphQLOperationName},
+		{&n.GraphQLOperationType, def.GraphQLOperationType},
+		{&n.GraphQLQueryHash, def.GraphQLQueryHash},
+		{&n.RPCMethod, def.RPCMethod},
+		{&n.TenantID, def.TenantID},
+		{&n.CorrelationID, def.CorrelationID},
+	}
+
+	for _, p := range pairs {
+		if *p.name == "" {
+			*p.name = p.def
+		}
+	}
+
+	return n
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,27 @@
+package echozapmiddleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitBytesWithDots(t *testing.T) {
+	body := []byte("{\"name\": \"Ünïcödé\", \"id\": 12345}")
+
+	for size := 0; size <= len(body)+1; size++ {
+		limited := limitBytesWithDots(body, size)
+		require.Equal(t, limitStringWithDots(string(body), size), string(limited), "size %d", size)
+	}
+
+	// the captured body is left intact
+	require.Equal(t, "{\"name\": \"Ünïcödé\", \"id\": 12345}", string(body))
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	require.Equal(t, http.MethodGet, normalizeMethod(http.MethodGet))
+	require.Equal(t, http.MethodPatch, normalizeMethod(http.MethodPatch))
+	require.Equal(t, "other", normalizeMethod("PROPFIND"))
+	require.Equal(t, "other", normalizeMethod("get"))
+}
@@ -0,0 +1,64 @@
+package echozapmiddleware
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LatencyFormat defines how the latency of a request is logged.
+type LatencyFormat string
+
+const (
+	// LatencyFormatHuman logs the latency as a string, e.g. "1.5ms".
+	LatencyFormatHuman LatencyFormat = ""
+	// LatencyFormatNanoseconds logs the latency as an integer number of nanoseconds.
+	LatencyFormatNanoseconds LatencyFormat = "ns"
+	// LatencyFormatMicroseconds logs the latency as a float number of microseconds.
+	LatencyFormatMicroseconds LatencyFormat = "us"
+	// LatencyFormatMilliseconds logs the latency as a float number of milliseconds.
+	LatencyFormatMilliseconds LatencyFormat = "ms"
+	// LatencyFormatSeconds logs the latency as a float number of seconds.
+	LatencyFormatSeconds LatencyFormat = "s"
+)
+
+// valid reports whether f is a known latency format.
+func (f LatencyFormat) valid() bool {
+	switch f {
+	case LatencyFormatHuman, LatencyFormatNanoseconds, LatencyFormatMicroseconds,
+		LatencyFormatMilliseconds, LatencyFormatSeconds:
+		return true
+	default:
+		return false
+	}
+}
+
+// appendLatency appends the latency field, followed by the human-readable one if requested.
+func appendLatency(fields []zapcore.Field, config ZapConfig, latency time.Duration) []zapcore.Field {
+	name := config.FieldNames.Latency
+
+	switch config.LatencyFormat {
+	case LatencyFormatNanoseconds:
+		fields = append(fields, zap.Int64(name, latency.Nanoseconds()))
+	case LatencyFormatMicroseconds:
+		fields = append(fields, zap.Float64(name, float64(latency)/float64(time.Microsecond)))
+	case LatencyFormatMilliseconds:
+		fields = append(fields, zap.Float64(name, float64(latency)/float64(time.Millisecond)))
+	case LatencyFormatSeconds:
+		fields = append(fields, zap.Float64(name, latency.Seconds()))
+	default:
+		if config.LatencyAsDuration {
+			return append(fields, zap.Duration(name, latency))
+		}
+
+		// the latency is already human-readable
+		return append(fields, zap.String(name, latency.String()))
+	}
+
+	if config.LatencyHuman {
+		fields = append(fields, zap.String(config.FieldNames.LatencyHuman, latency.String()))
+	}
+
+	return fields
+}
@@ -0,0 +1,122 @@
+package echozapmiddleware
+
+import (
+	"context"
+
+	contextlogger "github.com/adlandh/context-logger"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LoggerContextKey is the echo.Context key of the request-scoped logger.
+const LoggerContextKey = "logger"
+
+type loggerKey struct{}
+
+// ContextWithLogger returns a copy of ctx which holds logger.
+func ContextWithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFromContext returns the logger held by ctx, e.g. the request-scoped logger
+// stored by the middleware with InjectLogger enabled. If there is none, the global zap logger is returned.
+func LoggerFromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.L()
+}
+
+// Logger returns the request-scoped logger stored by the middleware with InjectLogger enabled.
+// If there is none, the global zap logger is returned.
+func Logger(c echo.Context) *zap.Logger {
+	if logger, ok := c.Get(LoggerContextKey).(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.L()
+}
+
+// lazyLogger builds the logger of a request on first use, as building it allocates.
+type lazyLogger struct {
+	// base is the logger of a middleware created without a context logger
+	base      *zap.Logger
+	ctxLogger *contextlogger.ContextLogger
+	ctx       context.Context
+	static    []zapcore.Field
+	logger    *zap.Logger
+}
+
+// get returns the logger of the request.
+func (l *lazyLogger) get() *zap.Logger {
+	if l.logger == nil {
+		l.logger = l.ctxLogger.Ctx(l.ctx)
+		if len(l.static) > 0 {
+			l.logger = l.logger.With(l.static...)
+		}
+	}
+
+	return l.logger
+}
+
+// background returns the logger of the middleware without the request context,
+// for entries logged by goroutines outliving the request.
+func (l *lazyLogger) background() *zap.Logger {
+	if l.base != nil && len(l.static) == 0 {
+		return l.base
+	}
+
+	logger := l.ctxLogger.Ctx(context.Background())
+	if len(l.static) > 0 {
+		logger = logger.With(l.static...)
+	}
+
+	return logger
+}
+
+// entryLogger returns the logger writing the request entry. If the logger of the request adds nothing
+// but the context, the entry is written by base with the context field, so the logger isn't built.
+func (l *lazyLogger) entryLogger() entryLogger {
+	if l.logger == nil && l.base != nil && len(l.static) == 0 {
+		return entryLogger{logger: l.base, ctx: l.ctx}
+	}
+
+	return entryLogger{logger: l.get()}
+}
+
+// entryLogger writes request entries, adding the context field if ctx is set.
+type entryLogger struct {
+	logger *zap.Logger
+	ctx    context.Context
+}
+
+// contextField returns the field of ctx contextlogger.ContextLogger adds to its loggers.
+func contextField(ctx context.Context) zapcore.Field {
+	return zapcore.Field{Key: contextlogger.ContextKey, Type: zapcore.SkipType, Interface: ctx}
+}
+
+// injectLogger stores the request-scoped logger in c and in the request context.
+func injectLogger(logger *zap.Logger, config ZapConfig, c echo.Context) {
+	logger = requestLogger(logger, config, c)
+	req := c.Request()
+
+	c.Set(LoggerContextKey, logger)
+	c.SetRequest(req.WithContext(ContextWithLogger(req.Context(), logger)))
+}
+
+// requestLogger returns logger with the correlation fields of the request.
+func requestLogger(logger *zap.Logger, config ZapConfig, c echo.Context) *zap.Logger {
+	req := c.Request()
+	names := config.FieldNames
+	fields := []zapcore.Field{
+		zap.String(names.RequestID, resolveRequestID(config, c)),
+		zap.String(names.Method, req.Method),
+	}
+
+	fields = appendURI(fields, config, c)
+	fields = appendCorrelationID(fields, config, c)
+
+	return logger.With(append(fields, addTrace(config, c)...)...)
+}
@@ -0,0 +1,63 @@
+package echozapmiddleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// unmatchedRoute is the route of requests which match no route, as their paths are chosen by clients.
+const unmatchedRoute = "unmatched"
+
+// RequestMetrics are the metrics of a handled request.
+// Method and Route are bounded, so they can be used as metric labels.
+type RequestMetrics struct {
+	// Method is the request method, or "other" if it's not a standard one
+	Method string
+	// Route is the path of the matched route, or "unmatched" if no route matched
+	Route string
+	// StatusClass is the class of the response status, e.g. "2xx"
+	StatusClass  string
+	Latency      time.Duration
+	RequestSize  int64
+	ResponseSize int64
+}
+
+// MetricsRecorder records the metrics of handled requests.
+// The prommetrics package provides one recording Prometheus metrics.
+type MetricsRecorder interface {
+	ObserveRequest(metrics RequestMetrics)
+}
+
+// recordMetrics passes the metrics of the request to recorder.
+func recordMetrics(recorder MetricsRecorder, c echo.Context, latency time.Duration) {
+	if recorder == nil {
+		return
+	}
+
+	route := c.Path()
+	if route == "" {
+		route = unmatchedRoute
+	}
+
+	req := c.Request()
+	res := c.Response()
+
+	recorder.ObserveRequest(RequestMetrics{
+		Method:       normalizeMethod(req.Method),
+		Route:        route,
+		StatusClass:  strconv.Itoa(res.Status/100) + "xx",
+		Latency:      latency,
+		RequestSize:  max(req.ContentLength, 0),
+		ResponseSize: res.Size,
+	})
+}
+
+// WithMetrics returns config which also records the metrics of every request with recorder,
+// e.g. with the Prometheus metrics of the prommetrics package.
+func (config ZapConfig) WithMetrics(recorder MetricsRecorder) ZapConfig {
+	config.metrics = recorder
+
+	return config
+}
@@ -2,10 +2,14 @@
 package echozapmiddleware
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
 	"time"
 
 	contextlogger "github.com/adlandh/context-logger"
-	"github.com/adlandh/response-dumper"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"go.uber.org/zap"
@@ -14,30 +18,461 @@ import (
 
 type BodySkipper func(c echo.Context) (skipReqBody, skipRespBody bool)
 
+// FieldsFunc returns additional fields to be added to the log entry.
+// It is invoked after the handler, so the response is already available.
+type FieldsFunc func(c echo.Context) []zapcore.Field
+
+// TenantExtractor returns the tenant of a multi-tenant service the request belongs to,
+// e.g. from a subdomain, a header or a claim of the token, or an empty string if it's unknown.
+type TenantExtractor func(c echo.Context) string
+
+// ErrorFieldsFunc returns additional fields describing a handler error, e.g. its type or whether it's retriable.
+type ErrorFieldsFunc func(err error) []zapcore.Field
+
+// ServerErrorFunc receives a 5xx request together with its handler error (if any) and the fields of its log entry,
+// e.g. to forward it to an error tracker.
+type ServerErrorFunc func(c echo.Context, err error, fields []zapcore.Field)
+
+// FieldFilter drops, renames or transforms the fields of a request entry before it is written.
+// The passed slice and the memory of its fields are reused for other entries, so they must not be kept.
+type FieldFilter func(fields []zapcore.Field) []zapcore.Field
+
+// LogEntry describes a written request entry.
+type LogEntry struct {
+	Message string
+	Level   zapcore.Level
+	Fields  []zapcore.Field
+}
+
+// Observer receives every written request entry, e.g. to assert it in tests or to derive custom metrics.
+type Observer func(entry LogEntry)
+
+// MessageFunc returns the message of the log entry of a request, e.g. "GET /ping 200".
+// If it returns an empty string, the default message is used.
+type MessageFunc func(c echo.Context, status int) string
+
+// LevelFunc returns the level of the log entry of a request.
+type LevelFunc func(status int, err error, latency time.Duration) zapcore.Level
+
+// BodyDecoder converts a binary body (e.g. protobuf or msgpack) of contentType to a human-readable string.
+// It returns false if the body can't be decoded.
+type BodyDecoder func(contentType string, body []byte) (string, bool)
+
+// HeaderMasker transforms values of a dumped header.
+// If it returns no values, the header is omitted from the log entry.
+type HeaderMasker func(name string, values []string) []string
+
 func defaultBodySkipper(_ echo.Context) (skipReqBody, skipRespBody bool) {
 	return
 }
 
 type (
 	// ZapConfig defines the config for Zap Logger middleware.
+	// Function fields are not (un)marshalled and have to be set separately.
 	ZapConfig struct {
 		// Skipper defines a function to skip middleware.
-		Skipper middleware.Skipper
+		Skipper middleware.Skipper `json:"-" yaml:"-"`
 
 		// BodySkipper defines a function to exclude body from logging
-		BodySkipper BodySkipper
+		BodySkipper BodySkipper `json:"-" yaml:"-"`
+
+		// LevelFunc defines a function to choose the level of the log entry.
+		// If nil, DefaultLevelFunc is used
+		LevelFunc LevelFunc `json:"-" yaml:"-"`
+
+		// ErrorFieldsFunc defines a function to add fields describing the handler error to the log entry
+		ErrorFieldsFunc ErrorFieldsFunc `json:"-" yaml:"-"`
+
+		// MessageFunc defines a function to build the message of the log entry.
+		// If nil, the message describes the status class, e.g. "Success"
+		MessageFunc MessageFunc `json:"-" yaml:"-"`
+
+		// Messages defines messages of specific statuses, e.g. 404: "Not found"
+		Messages map[int]string `json:"messages,omitempty" yaml:"messages,omitempty"`
+
+		// ClassMessages defines messages of status classes, e.g. "4xx": "Bad request".
+		// Messages take precedence over them
+		ClassMessages map[string]string `json:"class_messages,omitempty" yaml:"class_messages,omitempty"`
+
+		// FieldsFunc defines a function to add custom fields to the log entry
+		FieldsFunc FieldsFunc `json:"-" yaml:"-"`
+
+		// StaticFields defines fields added to every entry and to the injected logger, e.g. the region or the cluster.
+		// Middleware adds them to its logger once, the other constructors to the logger of every request
+		StaticFields []zapcore.Field `json:"-" yaml:"-"`
+
+		// TenantExtractor defines a function returning the tenant id of the request
+		TenantExtractor TenantExtractor `json:"-" yaml:"-"`
+
+		// SkipStatusCodes defines response statuses which are not logged
+		SkipStatusCodes []int `json:"skip_status_codes,omitempty" yaml:"skip_status_codes,omitempty"`
+
+		// SkipPaths defines route paths (exact or glob patterns) which are not logged
+		SkipPaths []string `json:"skip_paths,omitempty" yaml:"skip_paths,omitempty"`
+
+		// SkipPathRegexps defines route path regexps which are not logged
+		SkipPathRegexps []*regexp.Regexp `json:"-" yaml:"-"`
+
+		// SuccessSampleRate defines the fraction (0, 1] of 2xx and 3xx requests which are logged.
+		// 4xx and 5xx requests are always logged. If zero, all requests are logged
+		SuccessSampleRate float64 `json:"success_sample_rate,omitempty" yaml:"success_sample_rate,omitempty"`
+
+		// RouteSampleRates defines the fractions [0, 1] of 2xx and 3xx requests which are logged per route,
+		// overriding SuccessSampleRate. Keys are route paths (exact or glob patterns) optionally prefixed
+		// with a method, e.g. "GET /assets/*" or "/api/payments"
+		RouteSampleRates map[string]float64 `json:"route_sample_rates,omitempty" yaml:"route_sample_rates,omitempty"`
+
+		// MaxLogsPerSecond limits the number of log entries per second. Excess entries are dropped
+		// and their number is logged every second while entries are dropped. If zero, entries are not limited
+		MaxLogsPerSecond float64 `json:"max_logs_per_second,omitempty" yaml:"max_logs_per_second,omitempty"`
+
+		// RateLimitPerRoute applies MaxLogsPerSecond to every route separately
+		RateLimitPerRoute bool `json:"rate_limit_per_route" yaml:"rate_limit_per_route"`
+
+		// PropagateError returns the handler error to the outer middlewares instead of handling it with c.Error.
+		// The status of the error is logged, but the size of the error response is not known yet
+		PropagateError bool `json:"propagate_error" yaml:"propagate_error"`
+
+		// StackTraceOnServerError adds a stack trace to entries of 5xx responses and handler errors
+		// which carry no status, unlike echo.HTTPError
+		StackTraceOnServerError bool `json:"stack_trace_on_server_error" yaml:"stack_trace_on_server_error"`
+
+		// OnServerError defines a function called after 5xx entries are logged
+		OnServerError ServerErrorFunc `json:"-" yaml:"-"`
+
+		// Observer defines a function called with every written request entry
+		Observer Observer `json:"-" yaml:"-"`
+
+		// FieldFilter defines a function applied to the fields of every request entry before it is written
+		FieldFilter FieldFilter `json:"-" yaml:"-"`
+
+		// GenerateRequestID generates a request id if the request has no X-Request-ID header,
+		// so echo's RequestID middleware isn't needed. The id is set on the X-Request-ID response header
+		// and in the request context, see RequestIDFromContext
+		GenerateRequestID bool `json:"generate_request_id" yaml:"generate_request_id"`
+
+		// RequestIDGenerator defines a function to generate request ids.
+		// If nil, random strings of 32 characters are generated
+		RequestIDGenerator func() string `json:"-" yaml:"-"`
+
+		// TraceIDAsRequestID logs the trace id of the request span or the traceparent header as the request id
+		// when the request has no X-Request-ID, so every entry can still be correlated
+		TraceIDAsRequestID bool `json:"trace_id_as_request_id" yaml:"trace_id_as_request_id"`
+
+		// CorrelationIDHeader defines the request header with the end-to-end correlation id, e.g. X-Correlation-ID,
+		// which is logged besides the per-hop request id
+		CorrelationIDHeader string `json:"correlation_id_header,omitempty" yaml:"correlation_id_header,omitempty"`
+
+		// CorrelationIDContextKey defines the request context key of the correlation id,
+		// which is used if the request has no CorrelationIDHeader. The value must be a string
+		CorrelationIDContextKey any `json:"-" yaml:"-"`
+
+		// InjectLogger stores a logger with the request id, correlation id, method, uri and trace ids in echo.Context
+		// and in the request context. Handlers can get it with Logger and other code with LoggerFromContext
+		InjectLogger bool `json:"inject_logger" yaml:"inject_logger"`
+
+		// LogRequestStart logs an additional entry when a request arrives, so in-flight requests are visible
+		LogRequestStart bool `json:"log_request_start" yaml:"log_request_start"`
+
+		// LongRunningInterval defines the interval of entries logged while a request is in progress.
+		// If zero, no such entries are logged
+		LongRunningInterval time.Duration `json:"long_running_interval,omitempty" yaml:"long_running_interval,omitempty"`
+
+		// SuccessLevel defines the level of 2xx and 3xx entries when LevelFunc is not set, e.g. zapcore.DebugLevel.
+		// The default is zapcore.InfoLevel
+		SuccessLevel zapcore.Level `json:"success_level" yaml:"success_level"`
+
+		// RouteNotFoundAsInfo logs 404 responses of requests which don't match any route at Info level
+		RouteNotFoundAsInfo bool `json:"route_not_found_as_info" yaml:"route_not_found_as_info"`
+
+		// LatencyAsDuration logs the latency with zap.Duration, so it's encoded by the EncodeDuration
+		// of the encoder (nanoseconds by default) instead of as a string like "1.5ms"
+		LatencyAsDuration bool `json:"latency_as_duration" yaml:"latency_as_duration"`
+
+		// LatencyFormat defines the unit of the logged latency, e.g. LatencyFormatMilliseconds.
+		// It takes precedence over LatencyAsDuration
+		LatencyFormat LatencyFormat `json:"latency_format,omitempty" yaml:"latency_format,omitempty"`
+
+		// LatencyHuman adds the latency as a string like "1.5ms" when LatencyFormat is a number
+		LatencyHuman bool `json:"latency_human" yaml:"latency_human"`
+
+		// SlowRequestThreshold defines the latency above which successful requests are logged at Warn level
+		// with the slow field. If zero, requests are never marked as slow
+		SlowRequestThreshold time.Duration `json:"slow_request_threshold,omitempty" yaml:"slow_request_threshold,omitempty"`
+
+		// DedupWindow collapses identical error entries (same route, status and error message) within the window.
+		// The number of dropped entries is logged as repeat count with the next one. If zero, entries are not collapsed
+		DedupWindow time.Duration `json:"dedup_window,omitempty" yaml:"dedup_window,omitempty"`
+
+		// LogQueryParams adds the parsed query of the request as an object
+		LogQueryParams bool `json:"log_query_params" yaml:"log_query_params"`
+
+		// RedactQueryParams defines query parameters which values are redacted, case-insensitively,
+		// in the query and in every logged uri, e.g. the uri field and the requestUrl of GCP.
+		// If nil, DefaultSensitiveQueryParams are used. Set to empty slice to disable redaction
+		RedactQueryParams []string `json:"redact_query_params,omitempty" yaml:"redact_query_params,omitempty"`
+
+		// ExtraFields defines optional request fields of the log entry, e.g. the user agent
+		ExtraFields ExtraFieldsConfig `json:"extra_fields" yaml:"extra_fields"`
+
+		// UAParser defines a parser which expands the User-Agent header into browser, os, device and bot fields
+		UAParser UAParser `json:"-" yaml:"-"`
 
 		// add req headers & resp headers to tracing tags
-		AreHeadersDump bool
+		AreHeadersDump bool `json:"headers_dump" yaml:"headers_dump"`
+
+		// HeadersToLog defines headers to be dumped. If empty, all headers are dumped
+		HeadersToLog []string `json:"headers_to_log,omitempty" yaml:"headers_to_log,omitempty"`
+
+		// HeadersToExclude defines headers not to be dumped
+		HeadersToExclude []string `json:"headers_to_exclude,omitempty" yaml:"headers_to_exclude,omitempty"`
+
+		// SensitiveHeaders defines headers which values are redacted.
+		// If nil, DefaultSensitiveHeaders are used. Set to empty slice to disable redaction
+		SensitiveHeaders []string `json:"sensitive_headers,omitempty" yaml:"sensitive_headers,omitempty"`
+
+		// HeaderMasker defines a function to transform every dumped header.
+		// It is called after sensitive headers are redacted
+		HeaderMasker HeaderMasker `json:"-" yaml:"-"`
+
+		// FlatHeaders logs every header as a separate field (e.g. req.header.user_agent)
+		// instead of a single headers object
+		FlatHeaders bool `json:"flat_headers" yaml:"flat_headers"`
+
+		// Cookies defines the config for logging request cookies
+		Cookies CookieConfig `json:"cookies" yaml:"cookies"`
+
+		// SessionCookieName defines the cookie which value is logged as the session id, hashed with SHA-256,
+		// so the requests of a user journey can be correlated
+		SessionCookieName string `json:"session_cookie_name,omitempty" yaml:"session_cookie_name,omitempty"`
+
+		// RawSessionID logs the value of the session id instead of its SHA-256 hash.
+		// Session ids are bearer credentials, so anyone reading the logs could use a logged one
+		RawSessionID bool `json:"raw_session_id" yaml:"raw_session_id"`
+
+		// DebugHeader defines a request header, e.g. X-Debug-Log, which makes the entry of that request
+		// dump headers and bodies even if they are not dumped otherwise. It requires DebugSecret
+		DebugHeader string `json:"debug_header,omitempty" yaml:"debug_header,omitempty"`
+
+		// DebugSecret defines the value DebugHeader must have to take effect.
+		// The header is redacted in entries, and the secret is redacted in marshalled configs
+		DebugSecret string `json:"debug_secret,omitempty" yaml:"debug_secret,omitempty"`
 
 		// add req body & resp body to attributes
-		IsBodyDump bool
+		IsBodyDump bool `json:"body_dump" yaml:"body_dump"`
 
 		// prevent logging long http request bodies
-		LimitHTTPBody bool
+		LimitHTTPBody bool `json:"limit_http_body" yaml:"limit_http_body"`
 
 		// http body limit size (in bytes)
-		LimitSize int
+		LimitSize int `json:"limit_size" yaml:"limit_size"`
+
+		// MaxCapturedResponseBytes limits the number of response body bytes kept for logging.
+		// If zero, the whole response body is kept
+		MaxCapturedResponseBytes int `json:"max_captured_response_bytes,omitempty" yaml:"max_captured_response_bytes,omitempty"`
+
+		// BodyDumpContentTypes defines content types which bodies are dumped (e.g. "application/json", "text/*").
+		// If empty, bodies of all content types are dumped
+		BodyDumpContentTypes []string `json:"body_dump_content_types,omitempty" yaml:"body_dump_content_types,omitempty"`
+
+		// BodySkipContentTypes defines content types which bodies are excluded.
+		// If nil, DefaultBodySkipContentTypes are used
+		BodySkipContentTypes []string `json:"body_skip_content_types,omitempty" yaml:"body_skip_content_types,omitempty"`
+
+		// BodyDecoder defines a function to decode binary bodies before logging.
+		// Decoded bodies are logged even if their content type is skipped
+		BodyDecoder BodyDecoder `json:"-" yaml:"-"`
+
+		// BodyArchiver stores bodies exceeding LimitSize and their references are logged.
+		// Bodies are streamed to it while they are read and written, and its writes are made in background goroutines.
+		// Archive errors are counted as "archive_errors" in the "echozap" expvar map.
+		// Archived bodies are NOT redacted, and files of TempFileArchiver are kept until they are cleaned up
+		BodyArchiver BodyArchiver `json:"-" yaml:"-"`
+
+		// ArchiveContentTypes defines content types which bodies are archived by BodyArchiver (e.g. "text/csv", "image/*").
+		// If empty, no bodies are archived
+		ArchiveContentTypes []string `json:"archive_content_types,omitempty" yaml:"archive_content_types,omitempty"`
+
+		// RedactJSONFields defines JSON field paths (e.g. "password", "credit_card.number")
+		// which values are redacted in JSON bodies
+		RedactJSONFields []string `json:"redact_json_fields,omitempty" yaml:"redact_json_fields,omitempty"`
+
+		// RedactFormFields defines form fields which values are redacted, case-insensitively,
+		// in application/x-www-form-urlencoded and multipart/form-data bodies.
+		// If nil, DefaultSensitiveFormFields are used. Set to empty slice to disable redaction
+		RedactFormFields []string `json:"redact_form_fields,omitempty" yaml:"redact_form_fields,omitempty"`
+
+		// RedactXMLElements defines XML element names which content is redacted in XML bodies
+		RedactXMLElements []string `json:"redact_xml_elements,omitempty" yaml:"redact_xml_elements,omitempty"`
+
+		// BodyRedactors defines regexp replacements applied to bodies before truncation
+		BodyRedactors []Redactor `json:"-" yaml:"-"`
+
+		// BodyAsJSON logs valid JSON bodies as nested objects instead of strings
+		BodyAsJSON bool `json:"body_as_json" yaml:"body_as_json"`
+
+		// Base64BinaryBody logs bodies which are not valid UTF-8 base64 encoded
+		Base64BinaryBody bool `json:"base64_binary_body" yaml:"base64_binary_body"`
+
+		// BodyFormat defines how JSON and XML bodies are formatted before truncation
+		BodyFormat BodyFormat `json:"body_format,omitempty" yaml:"body_format,omitempty"`
+
+		// DatadogTrace adds trace ids in the Datadog decimal format,
+		// so logs are linked to Datadog APM traces
+		DatadogTrace bool `json:"datadog_trace" yaml:"datadog_trace"`
+
+		// RecordSpanEvent adds the summarized request as an event to the active OpenTelemetry span,
+		// regardless of whether the entry is dropped by sampling, SkipStatusCodes or the rate limit
+		RecordSpanEvent bool `json:"record_span_event" yaml:"record_span_event"`
+
+		// MarkSpanErrors sets the status of the active OpenTelemetry span to Error
+		// if the handler returns an error or the response status is 5xx
+		MarkSpanErrors bool `json:"mark_span_errors" yaml:"mark_span_errors"`
+
+		// GCP defines the config for Google Cloud Logging formatted entries
+		GCP GCPConfig `json:"gcp" yaml:"gcp"`
+
+		// EMF defines the config for AWS CloudWatch embedded metric format
+		EMF EMFConfig `json:"emf" yaml:"emf"`
+
+		// Async defines the config for writing request entries in background goroutines
+		Async AsyncConfig `json:"async" yaml:"async"`
+
+		// GraphQL defines the config for logging the operations of a GraphQL endpoint
+		GraphQL GraphQLConfig `json:"graphql" yaml:"graphql"`
+
+		// RPCMethods maps routes of REST endpoints fronting gRPC services, e.g. by grpc-gateway,
+		// to full gRPC method names, e.g. "GET /v1/users/:id": "users.v1.UserService/GetUser".
+		// Keys are route paths optionally prefixed with a method
+		RPCMethods map[string]string `json:"rpc_methods,omitempty" yaml:"rpc_methods,omitempty"`
+
+		// RPCMethodFunc defines a function returning the full gRPC method name of the request.
+		// RPCMethods is used if it returns an empty string
+		RPCMethodFunc RPCMethodFunc `json:"-" yaml:"-"`
+
+		// AccessLogFormat defines the message format of the log entries
+		AccessLogFormat AccessLogFormat `json:"access_log_format,omitempty" yaml:"access_log_format,omitempty"`
+
+		// FieldConvention defines the default keys of the emitted log fields
+		FieldConvention FieldConvention `json:"field_convention,omitempty" yaml:"field_convention,omitempty"`
+
+		// FieldNames defines the keys of the emitted log fields.
+		// Empty names fall back to the names of FieldConvention.
+		FieldNames FieldNames `json:"field_names" yaml:"field_names"`
+
+		pathSkipper      *pathSkipper
+		headersToLog     headerSet
+		headersToExclude headerSet
+		sensitiveHeaders headerSet
+		redactJSONPaths  [][]string
+		metrics          MetricsRecorder
+		routeSampler     *routeSampler
+		logLimiter       *logLimiter
+		deduplicator     *deduplicator
+		asyncWriter      *asyncWriter
+		rpcMethods       rpcMethods
+	}
+
+	// Redactor replaces all matches of Pattern in a body with Replacement.
+	// Replacement may contain regexp.Expand templates like $1.
+	Redactor struct {
+		Pattern     *regexp.Regexp
+		Replacement string
+	}
+
+	// FieldConvention defines a set of default log field names.
+	FieldConvention string
+
+	// FieldNames defines the keys used for the log fields.
+	FieldNames struct {
+		Status      string `json:"status,omitempty" yaml:"status,omitempty"`
+		Latency     string `json:"latency,omitempty" yaml:"latency,omitempty"`
+		RequestID   string `json:"request_id,omitempty" yaml:"request_id,omitempty"`
+		Method      string `json:"method,omitempty" yaml:"method,omitempty"`
+		URI         string `json:"uri,omitempty" yaml:"uri,omitempty"`
+		Host        string `json:"host,omitempty" yaml:"host,omitempty"`
+		RemoteIP    string `json:"remote_ip,omitempty" yaml:"remote_ip,omitempty"`
+		BytesIn     string `json:"bytes_in,omitempty" yaml:"bytes_in,omitempty"`
+		BytesOut    string `json:"bytes_out,omitempty" yaml:"bytes_out,omitempty"`
+		Upgraded    string `json:"upgraded,omitempty" yaml:"upgraded,omitempty"`
+		ReqHeaders  string `json:"req_headers,omitempty" yaml:"req_headers,omitempty"`
+		RespHeaders string `json:"resp_headers,omitempty" yaml:"resp_headers,omitempty"`
+		ReqHeader   string `json:"req_header,omitempty" yaml:"req_header,omitempty"`
+		ReqCookies  string `json:"req_cookies,omitempty" yaml:"req_cookies,omitempty"`
+		RespHeader  string `json:"resp_header,omitempty" yaml:"resp_header,omitempty"`
+		ReqBody     string `json:"req_body,omitempty" yaml:"req_body,omitempty"`
+		RespBody    string `json:"resp_body,omitempty" yaml:"resp_body,omitempty"`
+
+		ReqBodyEncoding   string `json:"req_body_encoding,omitempty" yaml:"req_body_encoding,omitempty"`
+		RespBodyEncoding  string `json:"resp_body_encoding,omitempty" yaml:"resp_body_encoding,omitempty"`
+		RespBodyTruncated string `json:"resp_body_truncated,omitempty" yaml:"resp_body_truncated,omitempty"`
+		RespBodySize      string `json:"resp_body_size,omitempty" yaml:"resp_body_size,omitempty"`
+
+		ReqBodyRef  string `json:"req_body_ref,omitempty" yaml:"req_body_ref,omitempty"`
+		RespBodyRef string `json:"resp_body_ref,omitempty" yaml:"resp_body_ref,omitempty"`
+
+		RespEvents         string `json:"resp_events,omitempty" yaml:"resp_events,omitempty"`
+		RespStreamDuration string `json:"resp_stream_duration,omitempty" yaml:"resp_stream_duration,omitempty"`
+
+		TraceID      string `json:"trace_id,omitempty" yaml:"trace_id,omitempty"`
+		SpanID       string `json:"span_id,omitempty" yaml:"span_id,omitempty"`
+		ParentSpanID string `json:"parent_span_id,omitempty" yaml:"parent_span_id,omitempty"`
+
+		Suppressed  string `json:"suppressed,omitempty" yaml:"suppressed,omitempty"`
+		RepeatCount string `json:"repeat_count,omitempty" yaml:"repeat_count,omitempty"`
+		Slow        string `json:"slow,omitempty" yaml:"slow,omitempty"`
+		Elapsed     string `json:"elapsed,omitempty" yaml:"elapsed,omitempty"`
+		Error       string `json:"error,omitempty" yaml:"error,omitempty"`
+
+		ErrorCode     string `json:"error_code,omitempty" yaml:"error_code,omitempty"`
+		ErrorMessage  string `json:"error_message,omitempty" yaml:"error_message,omitempty"`
+		ErrorInternal string `json:"error_internal,omitempty" yaml:"error_internal,omitempty"`
+		Stack         string `json:"stack,omitempty" yaml:"stack,omitempty"`
+
+		DatadogTraceID string `json:"datadog_trace_id,omitempty" yaml:"datadog_trace_id,omitempty"`
+		DatadogSpanID  string `json:"datadog_span_id,omitempty" yaml:"datadog_span_id,omitempty"`
+
+		LatencyHuman string `json:"latency_human,omitempty" yaml:"latency_human,omitempty"`
+
+		UserAgent  string `json:"user_agent,omitempty" yaml:"user_agent,omitempty"`
+		Referer    string `json:"referer,omitempty" yaml:"referer,omitempty"`
+		Proto      string `json:"proto,omitempty" yaml:"proto,omitempty"`
+		Route      string `json:"route,omitempty" yaml:"route,omitempty"`
+		Params     string `json:"params,omitempty" yaml:"params,omitempty"`
+		Query      string `json:"query,omitempty" yaml:"query,omitempty"`
+		BytesRead  string `json:"bytes_read,omitempty" yaml:"bytes_read,omitempty"`
+		Scheme     string `json:"scheme,omitempty" yaml:"scheme,omitempty"`
+		RemoteAddr string `json:"remote_addr,omitempty" yaml:"remote_addr,omitempty"`
+		AuthUser   string `json:"auth_user,omitempty" yaml:"auth_user,omitempty"`
+		SessionID  string `json:"session_id,omitempty" yaml:"session_id,omitempty"`
+
+		UABrowser string `json:"ua_browser,omitempty" yaml:"ua_browser,omitempty"`
+		UAOS      string `json:"ua_os,omitempty" yaml:"ua_os,omitempty"`
+		UADevice  string `json:"ua_device,omitempty" yaml:"ua_device,omitempty"`
+		UABot     string `json:"ua_bot,omitempty" yaml:"ua_bot,omitempty"`
+
+		AcceptLanguage string `json:"accept_language,omitempty" yaml:"accept_language,omitempty"`
+		RespETag       string `json:"resp_etag,omitempty" yaml:"resp_etag,omitempty"`
+		ReqIfNoneMatch string `json:"req_if_none_match,omitempty" yaml:"req_if_none_match,omitempty"`
+		CacheHit       string `json:"cache_hit,omitempty" yaml:"cache_hit,omitempty"`
+
+		RetryAfter         string `json:"retry_after,omitempty" yaml:"retry_after,omitempty"`
+		RateLimitLimit     string `json:"ratelimit_limit,omitempty" yaml:"ratelimit_limit,omitempty"`
+		RateLimitRemaining string `json:"ratelimit_remaining,omitempty" yaml:"ratelimit_remaining,omitempty"`
+		RateLimitReset     string `json:"ratelimit_reset,omitempty" yaml:"ratelimit_reset,omitempty"`
+
+		GraphQLOperationName string `json:"graphql_operation_name,omitempty" yaml:"graphql_operation_name,omitempty"`
+		GraphQLOperationType string `json:"graphql_operation_type,omitempty" yaml:"graphql_operation_type,omitempty"`
+		GraphQLQueryHash     string `json:"graphql_query_hash,omitempty" yaml:"graphql_query_hash,omitempty"`
+
+		RPCMethod string `json:"rpc_method,omitempty" yaml:"rpc_method,omitempty"`
+
+		TenantID string `json:"tenant_id,omitempty" yaml:"tenant_id,omitempty"`
+
+		CorrelationID string `json:"correlation_id,omitempty" yaml:"correlation_id,omitempty"`
+
+		// URLQuery is the key of the query of the request. If set, URI is the path without the query
+		URLQuery string `json:"url_query,omitempty" yaml:"url_query,omitempty"`
 	}
 )
 
@@ -50,81 +485,544 @@ var (
 		IsBodyDump:     false,
 		LimitHTTPBody:  true,
 		LimitSize:      500,
+		FieldNames:     DefaultFieldNames,
+	}
+
+	// DefaultSensitiveHeaders is the default list of headers which values are redacted.
+	DefaultSensitiveHeaders = []string{
+		echo.HeaderAuthorization,
+		echo.HeaderCookie,
+		echo.HeaderSetCookie,
+		"Proxy-Authorization",
+		"X-Api-Key",
+	}
+
+	// DefaultFieldNames is the default set of log field names.
+	DefaultFieldNames = FieldNames{
+		Status:      "status",
+		Latency:     "latency",
+		RequestID:   "request_id",
+		Method:      "method",
+		URI:         "uri",
+		Host:        "host",
+		RemoteIP:    "remote_ip",
+		BytesIn:     "bytes_in",
+		BytesOut:    "bytes_out",
+		Upgraded:    "upgraded",
+		ReqHeaders:  "req.headers",
+		RespHeaders: "resp.headers",
+		ReqHeader:   "req.header",
+		ReqCookies:  "req.cookies",
+		RespHeader:  "resp.header",
+		ReqBody:     "req.body",
+		RespBody:    "resp.body",
+
+		ReqBodyEncoding:   "req.body_encoding",
+		RespBodyEncoding:  "resp.body_encoding",
+		RespBodyTruncated: "resp.body_truncated",
+		RespBodySize:      "resp.body_size",
+
+		ReqBodyRef:  "req.body_ref",
+		RespBodyRef: "resp.body_ref",
+
+		RespEvents:         "resp.events",
+		RespStreamDuration: "resp.stream_duration",
+
+		TraceID:      "trace_id",
+		SpanID:       "span_id",
+		ParentSpanID: "parent_span_id",
+
+		Suppressed:  "suppressed",
+		RepeatCount: "repeat_count",
+		Slow:        "slow",
+		Elapsed:     "elapsed",
+		Error:       "error",
+
+		ErrorCode:     "error.code",
+		ErrorMessage:  "error.message",
+		ErrorInternal: "error.internal",
+		Stack:         "stack",
+
+		DatadogTraceID: "dd.trace_id",
+		DatadogSpanID:  "dd.span_id",
+
+		LatencyHuman: "latency_human",
+
+		UserAgent:  "user_agent",
+		Referer:    "referer",
+		Proto:      "proto",
+		Route:      "route",
+		Params:     "params",
+		Query:      "query",
+		BytesRead:  "bytes_read",
+		Scheme:     "scheme",
+		RemoteAddr: "remote_addr",
+		AuthUser:   "auth.user",
+		SessionID:  "session_id",
+
+		UABrowser: "ua.browser",
+		UAOS:      "ua.os",
+		UADevice:  "ua.device",
+		UABot:     "ua.bot",
+
+		AcceptLanguage: "accept_language",
+		RespETag:       "resp.etag",
+		ReqIfNoneMatch: "req.if_none_match",
+		CacheHit:       "cache_hit",
+
+		RetryAfter:         "retry_after",
+		RateLimitLimit:     "ratelimit.limit",
+		RateLimitRemaining: "ratelimit.remaining",
+		RateLimitReset:     "ratelimit.reset",
+
+		GraphQLOperationName: "graphql.operation_name",
+		GraphQLOperationType: "graphql.operation_type",
+		GraphQLQueryHash:     "graphql.query_hash",
+
+		RPCMethod: "rpc.method",
+
+		TenantID: "tenant_id",
+
+		CorrelationID: "correlation_id",
 	}
+
+	// SemConvFieldNames are the log field names following OpenTelemetry HTTP semantic conventions.
+	// Names which are not covered by the conventions fall back to DefaultFieldNames.
+	SemConvFieldNames = FieldNames{
+		Status:      "http.response.status_code",
+		Method:      "http.request.method",
+		URI:         "url.path",
+		URLQuery:    "url.query",
+		Host:        "server.address",
+		RemoteIP:    "client.address",
+		BytesIn:     "http.request.body.size",
+		BytesOut:    "http.response.body.size",
+		ReqHeaders:  "http.request.header",
+		RespHeaders: "http.response.header",
+		ReqHeader:   "http.request.header",
+		RespHeader:  "http.response.header",
+		UserAgent:   "user_agent.original",
+		Route:       "http.route",
+		Scheme:      "url.scheme",
+		SessionID:   "session.id",
+
+		GraphQLOperationName: "graphql.operation.name",
+		GraphQLOperationType: "graphql.operation.type",
+	}
+)
+
+const (
+	// FieldConventionDefault uses DefaultFieldNames.
+	FieldConventionDefault FieldConvention = ""
+	// FieldConventionSemConv uses SemConvFieldNames, which follow OpenTelemetry HTTP semantic conventions.
+	FieldConventionSemConv FieldConvention = "semconv"
 )
 
-func makeHandler(ctxLogger *contextlogger.ContextLogger, config ZapConfig) echo.MiddlewareFunc {
+// requestLog holds the data collected while handling a request.
+type requestLog struct {
+	start      time.Time
+	latency    time.Duration
+	err        error
+	upgraded   bool
+	reqBody    []byte
+	reqRead    *countingReader
+	reqArchive *archiveSink
+	respDumper *bodyDumper
+
+	graphQLBody []byte
+}
+
+// makeHandler returns the middleware. base is the logger the middleware is created with, or nil for a context logger.
+// It lets request entries be written without building a request logger.
+func makeHandler(base *zap.Logger, ctxLogger *contextlogger.ContextLogger, holder *ConfigHolder) echo.MiddlewareFunc {
+	// the entries EMF metrics are derived from must not be dropped
+	if config := holder.Config(); config.EMF.dropsEntries(config) {
+		ctxLogger.Ctx(context.Background()).Error("EMF metrics are incomplete", zap.Error(ErrEMFWithDroppedEntries))
+	}
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			if config.Skipper(c) || c.Request() == nil || c.Response() == nil {
+			config := holder.Config()
+
+			if config.Skipper(c) || c.Request() == nil || c.Response() == nil || config.pathSkipper.match(c.Path()) {
 				return next(c)
 			}
 
-			start := time.Now()
-			req := c.Request()
-			ctx := req.Context()
+			return handle(base, ctxLogger, config, next, c)
+		}
+	}
+}
 
-			var respDumper *response.Dumper
+func handle(
+	base *zap.Logger,
+	ctxLogger *contextlogger.ContextLogger,
+	config ZapConfig,
+	next echo.HandlerFunc,
+	c echo.Context,
+) error {
+	entry := requestLog{start: time.Now()}
+	config = debugConfig(config, c.Request())
 
-			var reqBody []byte
+	ensureRequestID(config, c)
 
-			if config.IsBodyDump {
-				defer func() {
-					c.SetRequest(req.WithContext(ctx))
-				}()
+	req := c.Request()
+	ctx := req.Context()
+	logger := lazyLogger{base: base, ctxLogger: ctxLogger, ctx: ctx, static: config.StaticFields}
 
-				respDumper, reqBody = prepareReqAndResp(c, config)
-			}
+	// wrapping the writer of an upgraded connection breaks hijacking
+	entry.upgraded = isUpgradeRequest(req)
 
-			err := next(c)
-			if err != nil {
-				c.Error(err)
-			}
+	if config.IsBodyDump && !entry.upgraded {
+		defer func() {
+			c.SetRequest(req.WithContext(ctx))
+			entry.reqArchive.discard()
+			releaseBodyDumper(c, entry.respDumper)
+		}()
 
-			res := c.Response()
+		prepareReqAndResp(c, config, &entry)
+	}
 
-			fields := []zapcore.Field{
-				zap.Int("status", res.Status),
-				zap.String("latency", time.Since(start).String()),
-				zap.String("request_id", getRequestID(c)),
-				zap.String("method", req.Method),
-				zap.String("uri", req.RequestURI),
-				zap.String("host", req.Host),
-				zap.String("remote_ip", c.RealIP()),
-			}
+	entry.graphQLBody = captureGraphQLBody(config, req, entry)
 
-			// add headers
-			fields = append(fields, addHeaders(config, req.Header, res.Header())...)
+	if config.InjectLogger {
+		injectLogger(logger.get(), config, c)
+	}
 
-			// add body
-			fields = append(fields, addBody(config, c, string(reqBody), respDumper)...)
+	if config.LogRequestStart {
+		logRequestStart(logger.get(), config, c)
+	}
 
-			logit(res.Status, ctxLogger.Ctx(ctx), fields)
+	// the heartbeat is stopped even if the handler panics
+	stopHeartbeat := startHeartbeat(&logger, config, c, entry.start)
+	defer stopHeartbeat()
 
-			return nil
-		}
+	entry.err = next(c)
+
+	if entry.err != nil {
+		handleError(config, c, entry.err)
+	}
+
+	entry.latency = time.Since(entry.start)
+
+	markSpanError(config, c, entry.err)
+	recordSpanEvent(config, c, entry.latency)
+	recordMetrics(config.metrics, c, entry.latency)
+	recordRequestStats(c.Response().Status)
+
+	logRequest(&logger, config, c, entry)
+
+	if config.PropagateError {
+		return entry.err
 	}
+
+	return nil
 }
 
-// MiddlewareWithContextLogger returns a Zap Logger middleware with context logger.
-func MiddlewareWithContextLogger(ctxLogger *contextlogger.ContextLogger, config ...ZapConfig) echo.MiddlewareFunc {
-	if len(config) == 0 {
-		config = []ZapConfig{DefaultZapConfig}
+// handleError lets echo handle err, or only sets the status which the error will be responded with
+// if the error is propagated to the outer middlewares.
+func handleError(config ZapConfig, c echo.Context, err error) {
+	if !config.PropagateError {
+		c.Error(err)
+		return
+	}
+
+	if c.Response().Committed {
+		return
+	}
+
+	status, ok := errorStatus(err)
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	c.Response().Status = status
+}
+
+func logRequest(logger *lazyLogger, config ZapConfig, c echo.Context, entry requestLog) {
+	req := c.Request()
+	status := c.Response().Status
+
+	if slices.Contains(config.SkipStatusCodes, status) || isSampledOut(config, req.Method, c.Path(), status) {
+		return
+	}
+
+	slow := config.SlowRequestThreshold > 0 && entry.latency > config.SlowRequestThreshold
+	level := entryLevel(config, c, status, entry, slow)
+
+	serverError := config.OnServerError != nil && isServerError(status, entry.err)
+
+	out := logger.entryLogger()
+
+	// entries dropped by the level of the logger aren't built at all, unless OnServerError gets their fields
+	if !serverError && !out.logger.Core().Enabled(level) {
+		return
+	}
+
+	// the route is only built for the deduplicator and the limiter.
+	// The method is normalized, as arbitrary methods would grow their state
+	var route string
+	if config.deduplicator != nil || config.logLimiter != nil {
+		route = normalizeMethod(req.Method) + " " + c.Path()
+	}
+
+	logged, repeats := config.deduplicator.check(route, status, entry.err, entry.start)
+	if !logged {
+		return
+	}
+
+	if !config.logLimiter.allow(config, logger, route) {
+		return
+	}
+
+	pooled, _ := entryPool.Get().(*entryBuffer)
+	pooled.fields = append(requestFields(pooled.fields, config, c, entry), entryMarkers(config, repeats, slow)...)
+	fields := filterFields(config, pooled.fields)
+
+	writeEntry(out, config, level, logMessage(config, c, entry.start, slow), fields, pooled)
+
+	if serverError {
+		config.OnServerError(c, entry.err, fields)
+	}
+}
+
+// filterFields applies FieldFilter to the fields of a request entry.
+func filterFields(config ZapConfig, fields []zapcore.Field) []zapcore.Field {
+	if config.FieldFilter == nil {
+		return fields
+	}
+
+	return config.FieldFilter(fields)
+}
+
+// writeEntry writes a request entry, possibly in the background, and recycles its pooled fields.
+func writeEntry(
+	logger entryLogger,
+	config ZapConfig,
+	level zapcore.Level,
+	message string,
+	fields []zapcore.Field,
+	pooled *entryBuffer,
+) {
+	if config.asyncWriter == nil {
+		writeEntryNow(logger, config, level, message, fields, pooled)
+		return
+	}
+
+	writeEntryAsync(logger, config, level, message, fields, pooled)
+}
+
+// writeEntryAsync queues the entry. It's kept apart from writeEntry,
+// as the closure moves config to the heap.
+func writeEntryAsync(
+	logger entryLogger,
+	config ZapConfig,
+	level zapcore.Level,
+	message string,
+	fields []zapcore.Field,
+	pooled *entryBuffer,
+) {
+	config.asyncWriter.write(func() {
+		writeEntryNow(logger, config, level, message, fields, pooled)
+	})
+}
+
+func writeEntryNow(
+	logger entryLogger,
+	config ZapConfig,
+	level zapcore.Level,
+	message string,
+	fields []zapcore.Field,
+	pooled *entryBuffer,
+) {
+	if logit(logger, level, message, fields) && config.Observer != nil {
+		config.Observer(LogEntry{Message: message, Level: level, Fields: fields})
 	}
 
-	if config[0].Skipper == nil {
-		config[0].Skipper = middleware.DefaultSkipper
+	recycleEntry(config, pooled)
+}
+
+// entryMarkers returns the fields marking collapsed and slow entries.
+func entryMarkers(config ZapConfig, repeats int64, slow bool) []zapcore.Field {
+	var fields []zapcore.Field
+
+	if repeats > 0 {
+		fields = append(fields, zap.Int64(config.FieldNames.RepeatCount, repeats))
+	}
+
+	if slow {
+		fields = append(fields, zap.Bool(config.FieldNames.Slow, true))
+	}
+
+	return fields
+}
+
+// entryLevel returns the level of the log entry of a request.
+func entryLevel(config ZapConfig, c echo.Context, status int, entry requestLog, slow bool) zapcore.Level {
+	// the path is empty if no route matched, unlike if a handler returned echo.ErrNotFound
+	if config.RouteNotFoundAsInfo && c.Path() == "" && errors.Is(entry.err, echo.ErrNotFound) {
+		return zapcore.InfoLevel
 	}
 
-	if config[0].BodySkipper == nil {
-		config[0].BodySkipper = defaultBodySkipper
+	level := config.LevelFunc(status, entry.err, entry.latency)
+	if slow {
+		level = max(level, zapcore.WarnLevel)
 	}
 
-	return makeHandler(ctxLogger, config[0])
+	return level
+}
+
+func requestFields(fields []zapcore.Field, config ZapConfig, c echo.Context, entry requestLog) []zapcore.Field {
+	req := c.Request()
+	fields = appendLogFields(fields, config, c, entry)
+	fields = appendCorrelationID(fields, config, c)
+	fields = appendExtraFields(fields, config, c)
+	fields = append(fields, addUserAgent(config, req)...)
+	fields = append(fields, addQuery(config, req.URL)...)
+	fields = append(fields, addGraphQL(config, req, entry.graphQLBody)...)
+	fields = append(fields, addRPCMethod(config, c)...)
+
+	if config.TenantExtractor != nil {
+		fields = appendNonEmpty(fields, config.FieldNames.TenantID, config.TenantExtractor(c))
+	}
+
+	// the request body is counted when it's dumped
+	if entry.respDumper != nil {
+		fields = append(fields, zap.Int64(config.FieldNames.BytesRead, entry.reqRead.bytesRead()))
+	}
+
+	// add trace ids
+	fields = append(fields, addTrace(config, c)...)
+	fields = append(fields, addDatadogTrace(config, c)...)
+	fields = append(fields, addGCP(config, c, entry.latency)...)
+	fields = append(fields, addEMF(config, c, entry.latency)...)
+
+	// add headers
+	fields = append(fields, addHeaders(config, req.Header, c.Response().Header())...)
+
+	if entry.upgraded {
+		fields = append(fields, zap.Bool(config.FieldNames.Upgraded, true))
+	}
+
+	// add error
+	fields = append(fields, addError(config, entry.err)...)
+
+	if config.StackTraceOnServerError && isServerError(c.Response().Status, entry.err) {
+		fields = append(fields, zap.StackSkip(config.FieldNames.Stack, 1))
+	}
+
+	// add cookies
+	fields = append(fields, addCookies(config, req)...)
+	fields = append(fields, addSessionID(config, req)...)
+
+	// add body
+	fields = append(fields, addBody(config, c, entry.reqBody, entry.reqArchive, entry.respDumper)...)
+
+	// add custom fields
+	if config.FieldsFunc != nil {
+		fields = append(fields, config.FieldsFunc(c)...)
+	}
+
+	return fields
+}
+
+func prepareConfig(config ZapConfig) ZapConfig {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+
+	if config.BodySkipper == nil {
+		config.BodySkipper = defaultBodySkipper
+	}
+
+	if config.LevelFunc == nil {
+		config.LevelFunc = successLevelFunc(config.SuccessLevel)
+	}
+
+	defaultNames := DefaultFieldNames
+	if config.FieldConvention == FieldConventionSemConv {
+		defaultNames = SemConvFieldNames.withDefaults(DefaultFieldNames)
+	}
+
+	config.FieldNames = config.FieldNames.withDefaults(defaultNames)
+	config.pathSkipper = newPathSkipper(config.SkipPaths, config.SkipPathRegexps)
+	config.routeSampler = newRouteSampler(config.RouteSampleRates)
+	config.logLimiter = newLogLimiter(config.MaxLogsPerSecond, config.RateLimitPerRoute)
+	config.deduplicator = newDeduplicator(config.DedupWindow)
+	config.rpcMethods = newRPCMethods(config.RPCMethods)
+	config.headersToLog = newHeaderSet(config.HeadersToLog)
+	config.headersToExclude = newHeaderSet(config.HeadersToExclude)
+
+	if config.SensitiveHeaders == nil {
+		config.SensitiveHeaders = DefaultSensitiveHeaders
+	}
+
+	config.sensitiveHeaders = newHeaderSet(sensitiveHeaderNames(config))
+
+	if config.RedactQueryParams == nil {
+		config.RedactQueryParams = DefaultSensitiveQueryParams
+	}
+
+	if config.RedactFormFields == nil {
+		config.RedactFormFields = DefaultSensitiveFormFields
+	}
+
+	config.Cookies = config.Cookies.prepare()
+
+	if config.BodySkipContentTypes == nil {
+		config.BodySkipContentTypes = DefaultBodySkipContentTypes
+	}
+
+	config.redactJSONPaths = splitJSONPaths(config.RedactJSONFields)
+
+	return config
+}
+
+// MiddlewareWithContextLogger returns a Zap Logger middleware with context logger.
+// Async is disabled with a warning, use MiddlewareWithContextLoggerAndConfigHolder instead.
+func MiddlewareWithContextLogger(ctxLogger *contextlogger.ContextLogger, config ...ZapConfig) echo.MiddlewareFunc {
+	holder := newMiddlewareConfigHolder(ctxLogger.Ctx(context.Background()), config)
+
+	return makeHandler(nil, ctxLogger, holder)
+}
+
+// MiddlewareWithContextLoggerAndConfigHolder returns a Zap Logger middleware with context logger,
+// which reads its config from holder on every request.
+func MiddlewareWithContextLoggerAndConfigHolder(
+	ctxLogger *contextlogger.ContextLogger,
+	holder *ConfigHolder,
+) echo.MiddlewareFunc {
+	return makeHandler(nil, ctxLogger, holder)
+}
+
+// MiddlewareWithConfigHolder returns a Zap Logger middleware, which reads its config from holder on every request.
+func MiddlewareWithConfigHolder(logger *zap.Logger, holder *ConfigHolder) echo.MiddlewareFunc {
+	return makeHandler(logger, contextlogger.WithContext(logger), holder)
 }
 
 // Middleware returns a Zap Logger middleware with config.
 // If config is not passed, DefaultZapConfig will be used.
+// Async is disabled with a warning, use MiddlewareWithConfigHolder instead.
 func Middleware(logger *zap.Logger, config ...ZapConfig) echo.MiddlewareFunc {
-	return MiddlewareWithContextLogger(contextlogger.WithContext(logger), config...)
+	if len(config) > 0 && len(config[0].StaticFields) > 0 {
+		static := config[0]
+		logger = logger.With(static.StaticFields...)
+		static.StaticFields = nil
+		config = []ZapConfig{static}
+	}
+
+	return makeHandler(logger, contextlogger.WithContext(logger), newMiddlewareConfigHolder(logger, config))
+}
+
+// newMiddlewareConfigHolder returns the holder of a middleware created without one.
+// Async is disabled, as its background writers could never be stopped, and logger warns about it.
+func newMiddlewareConfigHolder(logger *zap.Logger, config []ZapConfig) *ConfigHolder {
+	if len(config) > 0 && config[0].Async.Enabled {
+		logger.Warn("Async is disabled, as the middleware has no config holder", zap.Error(ErrAsyncWithoutConfigHolder))
+
+		sync := config[0]
+		sync.Async.Enabled = false
+		config = []ZapConfig{sync}
+	}
+
+	return NewConfigHolder(config...)
 }
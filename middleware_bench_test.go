@@ -0,0 +1,83 @@
+package echozapmiddleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newBenchmarkRequest returns a request to a middleware with the default config, logging to io.Discard.
+func newBenchmarkRequest() func() {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(io.Discard), zapcore.InfoLevel)
+	handler := Middleware(zap.New(core))(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/ping?x=1", nil)
+	w := httptest.NewRecorder()
+	c := e.NewContext(req, w)
+	c.SetPath("/ping")
+
+	return func() {
+		c.Reset(req, w)
+		_ = handler(c)
+	}
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	request := newBenchmarkRequest()
+
+	b.ReportAllocs()
+
+	for range b.N {
+		request()
+	}
+}
+
+func TestMiddlewareAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("sync.Pool drops items randomly with the race detector")
+	}
+
+	request := newBenchmarkRequest()
+
+	// the latency string is the only allocation, as cores may keep it after the pooled entry is reused
+	if allocs := testing.AllocsPerRun(100, request); allocs > 1 {
+		t.Errorf("default config allocates %v times per request, want at most 1", allocs)
+	}
+}
+
+func TestMiddlewareKeptEntries(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	latency := time.Duration(0)
+	handler := Middleware(zap.New(core))(func(c echo.Context) error {
+		time.Sleep(latency)
+		return c.NoContent(http.StatusOK)
+	})
+
+	e := echo.New()
+
+	for _, latency = range []time.Duration{3 * time.Millisecond, 0, 0} {
+		req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+		_ = handler(e.NewContext(req, httptest.NewRecorder()))
+	}
+
+	entries := logs.All()
+	require.Len(t, entries, 3)
+
+	first, ok := entries[0].ContextMap()["latency"].(string)
+	require.True(t, ok)
+
+	parsed, err := time.ParseDuration(first)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, parsed, 3*time.Millisecond)
+}
@@ -0,0 +1,144 @@
+package echozapmiddleware
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type multipartFile struct {
+	field       string
+	filename    string
+	contentType string
+	size        int64
+	// truncated is set if the file is cut off by the end of the captured body, so its size is unknown
+	truncated bool
+}
+
+func (f multipartFile) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("field", f.field)
+	enc.AddString("filename", f.filename)
+	enc.AddString("content_type", f.contentType)
+
+	if f.truncated {
+		enc.AddBool("truncated", true)
+	} else {
+		enc.AddInt64("size", f.size)
+	}
+
+	return nil
+}
+
+type multipartBody struct {
+	values *formValues
+	files  []multipartFile
+	// truncated is set if the captured body ends before the closing boundary, so parts may be missing
+	truncated bool
+}
+
+func (b *multipartBody) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	if err := enc.AddObject("fields", b.values); err != nil {
+		return err
+	}
+
+	if b.truncated {
+		enc.AddBool("truncated", true)
+	}
+
+	return enc.AddArray("files", zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+		for _, file := range b.files {
+			if err := enc.AppendObject(file); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// multipartBodyField returns the request body field with form values and file metadata
+// for multipart/form-data requests instead of the raw multipart stream.
+func multipartBodyField(config ZapConfig, req *http.Request, body string) (zapcore.Field, bool) {
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+	if err != nil || mediaType != echo.MIMEMultipartForm || params["boundary"] == "" {
+		return zapcore.Field{}, false
+	}
+
+	result := &multipartBody{
+		values: newFormValues(config),
+	}
+
+	// prefer the form parsed by the handler, as the captured body can be truncated.
+	// Parts cut off by the truncation are marked, as their sizes and the parts after them are unknown
+	if form := req.MultipartForm; form != nil {
+		fillFromForm(result, form)
+	} else {
+		fillFromBody(result, multipart.NewReader(strings.NewReader(body), params["boundary"]))
+	}
+
+	return zap.Object(config.FieldNames.ReqBody, result), true
+}
+
+func fillFromForm(result *multipartBody, form *multipart.Form) {
+	for _, name := range sortedKeys(form.Value) {
+		for _, value := range form.Value[name] {
+			result.values.add(name, value)
+		}
+	}
+
+	for _, field := range sortedKeys(form.File) {
+		for _, file := range form.File[field] {
+			result.files = append(result.files, multipartFile{
+				field:       field,
+				filename:    file.Filename,
+				contentType: file.Header.Get(echo.HeaderContentType),
+				size:        file.Size,
+			})
+		}
+	}
+}
+
+func fillFromBody(result *multipartBody, reader *multipart.Reader) {
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			// a wrapped EOF means the closing boundary is missing
+			result.truncated = err != io.EOF //nolint:errorlint
+
+			return
+		}
+
+		if part.FileName() == "" {
+			// a value cut off by the truncation is logged as far as it was captured
+			value, err := io.ReadAll(part)
+			result.values.add(part.FormName(), string(value))
+
+			if err != nil {
+				result.truncated = true
+				return
+			}
+
+			continue
+		}
+
+		size, err := io.Copy(io.Discard, part)
+		result.files = append(result.files, multipartFile{
+			field:       part.FormName(),
+			filename:    part.FileName(),
+			contentType: part.Header.Get(echo.HeaderContentType),
+			size:        size,
+			truncated:   err != nil,
+		})
+
+		if err != nil {
+			result.truncated = true
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package echozapmiddleware
+
+import (
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// pooledFieldsCap fits the fields of an entry with headers and bodies.
+	pooledFieldsCap = 32
+	// maxPooledBodyBytes keeps large response buffers from being held by the pool.
+	maxPooledBodyBytes = 64 << 10
+)
+
+// entryBuffer holds the reusable memory of a request entry.
+type entryBuffer struct {
+	fields []zapcore.Field
+}
+
+var (
+	entryPool = sync.Pool{
+		New: func() any {
+			return &entryBuffer{fields: make([]zapcore.Field, 0, pooledFieldsCap)}
+		},
+	}
+
+	bodyDumperPool = sync.Pool{
+		New: func() any {
+			return new(bodyDumper)
+		},
+	}
+)
+
+// recycleEntry returns the memory of a written entry to the pool,
+// unless its fields were passed to hooks which may keep them.
+func recycleEntry(config ZapConfig, b *entryBuffer) {
+	if config.Observer != nil || config.OnServerError != nil {
+		return
+	}
+
+	clear(b.fields)
+	b.fields = b.fields[:0]
+	entryPool.Put(b)
+}
+
+// releaseBodyDumper restores the response writer wrapped by d and returns d to the pool.
+func releaseBodyDumper(c echo.Context, d *bodyDumper) {
+	if d == nil {
+		return
+	}
+
+	if c.Response().Writer == d {
+		c.Response().Writer = d.ResponseWriter
+	}
+
+	d.archive.discard()
+
+	if d.buf.Cap() > maxPooledBodyBytes {
+		return
+	}
+
+	d.buf.Reset()
+	*d = bodyDumper{buf: d.buf}
+	bodyDumperPool.Put(d)
+}
@@ -0,0 +1,75 @@
+// Package prommetrics records the request metrics of echozapmiddleware as Prometheus metrics.
+// It's a separate package, so the middleware doesn't depend on the Prometheus client.
+package prommetrics
+
+import (
+	"fmt"
+
+	echozapmiddleware "github.com/adlandh/echo-zap-middleware"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "echo"
+
+var labels = []string{"method", "route", "status_class"}
+
+// Metrics is an echozapmiddleware.MetricsRecorder updating request count, latency and size metrics
+// labeled by method, route and status class.
+type Metrics struct {
+	requests     *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	requestSize  *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+}
+
+// New returns Metrics registered with registerer.
+func New(registerer prometheus.Registerer) (*Metrics, error) {
+	sizeBuckets := prometheus.ExponentialBuckets(100, 10, 6)
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Total number of HTTP requests.",
+		}, labels),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency in seconds.",
+			Buckets:   prometheus.DefBuckets,
+		}, labels),
+		requestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_size_bytes",
+			Help:      "HTTP request body size in bytes.",
+			Buckets:   sizeBuckets,
+		}, labels),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "response_size_bytes",
+			Help:      "HTTP response body size in bytes.",
+			Buckets:   sizeBuckets,
+		}, labels),
+	}
+
+	for _, collector := range []prometheus.Collector{m.requests, m.latency, m.requestSize, m.responseSize} {
+		if err := registerer.Register(collector); err != nil {
+			return nil, fmt.Errorf("error registering metrics: %w", err)
+		}
+	}
+
+	return m, nil
+}
+
+// ObserveRequest updates the metrics with a handled request.
+func (m *Metrics) ObserveRequest(metrics echozapmiddleware.RequestMetrics) {
+	labels := prometheus.Labels{
+		"method":       metrics.Method,
+		"route":        metrics.Route,
+		"status_class": metrics.StatusClass,
+	}
+
+	m.requests.With(labels).Inc()
+	m.latency.With(labels).Observe(metrics.Latency.Seconds())
+	m.requestSize.With(labels).Observe(float64(metrics.RequestSize))
+	m.responseSize.With(labels).Observe(float64(metrics.ResponseSize))
+}
@@ -0,0 +1,65 @@
+package prommetrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echozapmiddleware "github.com/adlandh/echo-zap-middleware"
+	"github.com/adlandh/echo-zap-middleware/prommetrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	metrics, err := prommetrics.New(registry)
+	require.NoError(t, err)
+
+	_, err = prommetrics.New(registry)
+	require.Error(t, err)
+
+	router := echo.New()
+	router.Use(echozapmiddleware.Middleware(zap.NewNop(), echozapmiddleware.DefaultZapConfig.WithMetrics(metrics)))
+	router.GET("/ping", func(c echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	})
+
+	for _, method := range []string{http.MethodGet, "PROPFIND", "FOO"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, "/ping", nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing/1", nil))
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	require.Len(t, families, 4)
+
+	for _, family := range families {
+		if family.GetName() != "echo_requests_total" {
+			continue
+		}
+
+		series := map[string]float64{}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			series[labels["method"]+" "+labels["route"]+" "+labels["status_class"]] = metric.GetCounter().GetValue()
+		}
+
+		require.Equal(t, map[string]float64{
+			"GET /ping 2xx":     1,
+			"other /ping 4xx":   2,
+			"GET unmatched 4xx": 1,
+		}, series)
+	}
+}
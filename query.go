@@ -0,0 +1,83 @@
+package echozapmiddleware
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// DefaultSensitiveQueryParams is the default list of query parameters which values are redacted.
+var DefaultSensitiveQueryParams = []string{
+	"token",
+	"access_token",
+	"refresh_token",
+	"api_key",
+	"apikey",
+	"password",
+	"secret",
+	"signature",
+}
+
+// queryParams marshals the parsed query of a request. It's parsed only when the entry is encoded.
+type queryParams struct {
+	rawQuery  string
+	sensitive []string
+}
+
+func (q queryParams) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	values, _ := url.ParseQuery(q.rawQuery) // a malformed pair doesn't prevent logging the others
+
+	for _, name := range sortedKeys(values) {
+		params := values[name]
+
+		if isSensitiveParam(name, q.sensitive) {
+			for i := range params {
+				params[i] = redactedValue
+			}
+		}
+
+		if len(params) == 1 {
+			enc.AddString(name, params[0])
+			continue
+		}
+
+		if err := enc.AddArray(name, zapcore.ArrayMarshalerFunc(func(enc zapcore.ArrayEncoder) error {
+			for _, param := range params {
+				enc.AppendString(param)
+			}
+
+			return nil
+		})); err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// isSensitiveParam reports whether the value of the query parameter is redacted.
+// name may be escaped.
+func isSensitiveParam(name string, sensitive []string) bool {
+	if strings.ContainsAny(name, "%+") {
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+	}
+
+	return slices.ContainsFunc(sensitive, func(s string) bool { return strings.EqualFold(s, name) })
+}
+
+// addQuery returns the parsed query of the request with the sensitive values redacted.
+func addQuery(config ZapConfig, u *url.URL) []zapcore.Field {
+	if !config.LogQueryParams || u == nil || u.RawQuery == "" {
+		return nil
+	}
+
+	return []zapcore.Field{zap.Object(config.FieldNames.Query, queryParams{
+		rawQuery:  u.RawQuery,
+		sensitive: config.RedactQueryParams,
+	})}
+}
@@ -0,0 +1,5 @@
+//go:build race
+
+package echozapmiddleware
+
+const raceEnabled = true
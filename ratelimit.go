@@ -0,0 +1,120 @@
+package echozapmiddleware
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+const (
+	suppressedMessage = "Log entries suppressed"
+	// suppressedReportInterval is how often the number of suppressed entries is logged.
+	suppressedReportInterval = time.Second
+	// maxRateLimitRoutes bounds the limiters kept with RateLimitPerRoute. Further routes share one limiter.
+	maxRateLimitRoutes = 1024
+)
+
+// logLimiter limits the number of log entries per second, globally or per route.
+type logLimiter struct {
+	limit    rate.Limit
+	burst    int
+	perRoute bool
+	global   *rate.Limiter
+	mu       sync.Mutex
+	routes   map[string]*rate.Limiter
+	// overflow is shared by the routes exceeding maxRateLimitRoutes
+	overflow   *rate.Limiter
+	interval   time.Duration
+	suppressed atomic.Int64
+	// reporting is set while a goroutine logs the number of suppressed entries
+	reporting atomic.Bool
+}
+
+func newLogLimiter(perSecond float64, perRoute bool) *logLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+
+	l := &logLimiter{
+		limit:    rate.Limit(perSecond),
+		burst:    max(1, int(math.Ceil(perSecond))),
+		perRoute: perRoute,
+		interval: suppressedReportInterval,
+	}
+
+	if perRoute {
+		l.routes = make(map[string]*rate.Limiter)
+		l.overflow = rate.NewLimiter(l.limit, l.burst)
+	} else {
+		l.global = rate.NewLimiter(l.limit, l.burst)
+	}
+
+	return l
+}
+
+func (l *logLimiter) limiter(route string) *rate.Limiter {
+	if !l.perRoute {
+		return l.global
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limiter, ok := l.routes[route]
+	if ok {
+		return limiter
+	}
+
+	if len(l.routes) >= maxRateLimitRoutes {
+		return l.overflow
+	}
+
+	limiter = rate.NewLimiter(l.limit, l.burst)
+	l.routes[route] = limiter
+
+	return limiter
+}
+
+// allow reports whether an entry of route may be logged. Suppressed entries are counted
+// and their number is logged every interval while entries are being suppressed.
+func (l *logLimiter) allow(config ZapConfig, logger *lazyLogger, route string) bool {
+	if l == nil {
+		return true
+	}
+
+	if l.limiter(route).Allow() {
+		return true
+	}
+
+	l.suppressed.Add(1)
+
+	if l.reporting.CompareAndSwap(false, true) {
+		go l.report(logger.background(), config.FieldNames.Suppressed)
+	}
+
+	return false
+}
+
+// report logs the number of entries suppressed every interval, until no more entries are suppressed.
+func (l *logLimiter) report(logger *zap.Logger, name string) {
+	ticker := time.NewTicker(l.interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if suppressed := l.suppressed.Swap(0); suppressed > 0 {
+			logger.Warn(suppressedMessage, zap.Int64(name, suppressed))
+			continue
+		}
+
+		l.reporting.Store(false)
+
+		// an entry suppressed meanwhile may have seen the flag still set
+		if l.suppressed.Load() == 0 || !l.reporting.CompareAndSwap(false, true) {
+			return
+		}
+	}
+}
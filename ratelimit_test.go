@@ -0,0 +1,71 @@
+package echozapmiddleware
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"golang.org/x/time/rate"
+)
+
+func TestLogLimiter(t *testing.T) {
+	config := prepareConfig(DefaultZapConfig)
+
+	t.Run("global", func(t *testing.T) {
+		core, logs := observer.New(zapcore.InfoLevel)
+		logger := &lazyLogger{base: zap.New(core)}
+		l := newLogLimiter(0.001, false)
+		l.interval = 10 * time.Millisecond
+
+		require.True(t, l.allow(config, logger, "GET /a"))
+
+		for i := 0; i < 3; i++ {
+			require.False(t, l.allow(config, logger, "GET /b"))
+		}
+
+		require.Eventually(t, func() bool {
+			return logs.FilterMessage(suppressedMessage).Len() == 1
+		}, time.Second, time.Millisecond)
+		require.EqualValues(t, 3, logs.FilterMessage(suppressedMessage).All()[0].ContextMap()["suppressed"])
+
+		// the reporting goroutine stops once no entries are suppressed
+		require.Eventually(t, func() bool {
+			return !l.reporting.Load()
+		}, time.Second, time.Millisecond)
+
+		// refill the bucket
+		l.global = rate.NewLimiter(rate.Inf, 1)
+
+		require.True(t, l.allow(config, logger, "GET /a"))
+		require.Equal(t, 1, logs.FilterMessage(suppressedMessage).Len())
+	})
+
+	t.Run("per route", func(t *testing.T) {
+		logger := &lazyLogger{base: zap.NewNop()}
+		l := newLogLimiter(0.001, true)
+
+		require.True(t, l.allow(config, logger, "GET /a"))
+		require.False(t, l.allow(config, logger, "GET /a"))
+		require.True(t, l.allow(config, logger, "GET /b"))
+	})
+
+	t.Run("routes are bounded", func(t *testing.T) {
+		logger := &lazyLogger{base: zap.NewNop()}
+		l := newLogLimiter(0.001, true)
+
+		for i := 0; i < maxRateLimitRoutes+10; i++ {
+			l.allow(config, logger, "GET /"+strconv.Itoa(i))
+		}
+
+		require.Len(t, l.routes, maxRateLimitRoutes)
+		require.False(t, l.allow(config, logger, "GET /other"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		require.True(t, newLogLimiter(0, false).allow(config, nil, "GET /a"))
+	})
+}
@@ -0,0 +1,83 @@
+package echozapmiddleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+func splitJSONPaths(paths []string) [][]string {
+	result := make([][]string, 0, len(paths))
+	for _, p := range paths {
+		result = append(result, strings.Split(p, "."))
+	}
+
+	return result
+}
+
+// redactJSON replaces values of the given field paths with redactedValue.
+// Bodies which don't look like JSON are returned as is, while JSON bodies
+// which can't be parsed (e.g. truncated ones) are excluded, as they can't be redacted.
+func redactJSON(paths [][]string, body string) string {
+	if len(paths) == 0 || !looksLikeJSON(body) {
+		return body
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var data any
+	if err := decoder.Decode(&data); err != nil {
+		return excludedValue
+	}
+
+	for _, path := range paths {
+		redactJSONPath(data, path)
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(data); err != nil {
+		return excludedValue
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func redactJSONPath(data any, path []string) {
+	switch v := data.(type) {
+	case []any:
+		for _, item := range v {
+			redactJSONPath(item, path)
+		}
+	case map[string]any:
+		value, ok := v[path[0]]
+		if !ok {
+			return
+		}
+
+		if len(path) == 1 {
+			v[path[0]] = redactedValue
+			return
+		}
+
+		redactJSONPath(value, path[1:])
+	}
+}
+
+func looksLikeJSON(body string) bool {
+	body = strings.TrimSpace(body)
+
+	return strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[")
+}
+
+func redactBody(redactors []Redactor, body string) string {
+	for _, redactor := range redactors {
+		body = redactor.Pattern.ReplaceAllString(body, redactor.Replacement)
+	}
+
+	return body
+}
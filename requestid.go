@@ -0,0 +1,59 @@
+package echozapmiddleware
+
+import (
+	"context"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/gommon/random"
+	"go.uber.org/zap/zapcore"
+)
+
+// requestIDLength is the length of generated request ids, the same as of echo's RequestID middleware.
+const requestIDLength = 32
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request id generated by the middleware with GenerateRequestID enabled.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func generateRequestID() string {
+	return random.String(requestIDLength)
+}
+
+// ensureRequestID generates a request id if the request has none and sets it
+// on the response header and in the request context.
+func ensureRequestID(config ZapConfig, c echo.Context) {
+	if !config.GenerateRequestID || getRequestID(c) != "" {
+		return
+	}
+
+	generator := config.RequestIDGenerator
+	if generator == nil {
+		generator = generateRequestID
+	}
+
+	id := generator()
+	req := c.Request()
+
+	c.Response().Header().Set(echo.HeaderXRequestID, id)
+	c.SetRequest(req.WithContext(context.WithValue(req.Context(), requestIDKey{}, id)))
+}
+
+// appendCorrelationID appends the correlation id of the request, taken from CorrelationIDHeader
+// or from the value of CorrelationIDContextKey in the request context.
+func appendCorrelationID(fields []zapcore.Field, config ZapConfig, c echo.Context) []zapcore.Field {
+	var id string
+
+	if config.CorrelationIDHeader != "" {
+		id = c.Request().Header.Get(config.CorrelationIDHeader)
+	}
+
+	if id == "" && config.CorrelationIDContextKey != nil {
+		id, _ = c.Request().Context().Value(config.CorrelationIDContextKey).(string)
+	}
+
+	return appendNonEmpty(fields, config.FieldNames.CorrelationID, id)
+}
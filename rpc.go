@@ -0,0 +1,57 @@
+package echozapmiddleware
+
+import (
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RPCMethodFunc returns the full gRPC method name of a request, e.g. "users.v1.UserService/GetUser",
+// or an empty string if the request is not mapped to a method.
+type RPCMethodFunc func(c echo.Context) string
+
+// rpcMethods maps "METHOD /route" keys of RPCMethods to gRPC method names.
+type rpcMethods map[string]string
+
+func newRPCMethods(methods map[string]string) rpcMethods {
+	if len(methods) == 0 {
+		return nil
+	}
+
+	routes := make(rpcMethods, len(methods))
+
+	for key, name := range methods {
+		method, route := splitRouteKey(key)
+		routes[method+" "+route] = name
+	}
+
+	return routes
+}
+
+// lookup returns the gRPC method name of the route. Keys with the request method take precedence.
+func (m rpcMethods) lookup(method, route string) string {
+	if name, ok := m[method+" "+route]; ok {
+		return name
+	}
+
+	return m[" "+route]
+}
+
+// addRPCMethod returns the gRPC method name of the request, given by RPCMethodFunc or by RPCMethods.
+func addRPCMethod(config ZapConfig, c echo.Context) []zapcore.Field {
+	var name string
+
+	if config.RPCMethodFunc != nil {
+		name = config.RPCMethodFunc(c)
+	}
+
+	if name == "" && config.rpcMethods != nil {
+		name = config.rpcMethods.lookup(c.Request().Method, c.Path())
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	return []zapcore.Field{zap.String(config.FieldNames.RPCMethod, name)}
+}
@@ -0,0 +1,111 @@
+package echozapmiddleware
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// routeSampler matches routes against RouteSampleRates patterns like "GET /assets/*" or "/api/payments".
+type routeSampler struct {
+	exact map[string]float64
+	globs []routeSampleGlob
+}
+
+type routeSampleGlob struct {
+	method  string
+	pattern string
+	rate    float64
+}
+
+func newRouteSampler(rates map[string]float64) *routeSampler {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	sampler := &routeSampler{exact: make(map[string]float64, len(rates))}
+
+	for key, rate := range rates {
+		method, pattern := splitRouteKey(key)
+		if !strings.ContainsAny(pattern, `*?[\`) {
+			sampler.exact[method+" "+pattern] = rate
+			continue
+		}
+
+		sampler.globs = append(sampler.globs, routeSampleGlob{method: method, pattern: pattern, rate: rate})
+	}
+
+	// longer patterns are more specific, so they are checked first
+	slices.SortFunc(sampler.globs, func(a, b routeSampleGlob) int {
+		return cmp.Or(
+			cmp.Compare(len(b.pattern), len(a.pattern)),
+			cmp.Compare(b.method, a.method),
+			strings.Compare(a.pattern, b.pattern),
+		)
+	})
+
+	return sampler
+}
+
+// splitRouteKey splits "GET /path" into the method and the path. The method is empty if it's missing.
+func splitRouteKey(key string) (string, string) {
+	method, pattern, ok := strings.Cut(strings.TrimSpace(key), " ")
+	if !ok {
+		return "", method
+	}
+
+	return strings.ToUpper(method), strings.TrimSpace(pattern)
+}
+
+// rate returns the sample rate of the route and whether it's configured.
+func (s *routeSampler) rate(method, route string) (float64, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	if rate, ok := s.exact[method+" "+route]; ok {
+		return rate, true
+	}
+
+	if rate, ok := s.exact[" "+route]; ok {
+		return rate, true
+	}
+
+	for _, glob := range s.globs {
+		if glob.method != "" && glob.method != method {
+			continue
+		}
+
+		if ok, _ := path.Match(glob.pattern, route); ok {
+			return glob.rate, true
+		}
+	}
+
+	return 0, false
+}
+
+// isSampledOut reports whether the entry of a successful request is dropped by sampling.
+// Client and server errors are always logged.
+func isSampledOut(config ZapConfig, method, route string, status int) bool {
+	if status >= http.StatusBadRequest {
+		return false
+	}
+
+	rate, ok := config.routeSampler.rate(method, route)
+	if !ok {
+		if config.SuccessSampleRate <= 0 {
+			return false
+		}
+
+		rate = config.SuccessSampleRate
+	}
+
+	if rate >= 1 {
+		return false
+	}
+
+	return rand.Float64() >= rate //nolint:gosec // sampling doesn't need a secure source
+}
@@ -0,0 +1,88 @@
+package echozapmiddleware
+
+import (
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// maxCachedPaths bounds the cache of glob and regexp results, as paths of unmatched requests are arbitrary.
+const maxCachedPaths = 1024
+
+// pathSkipper matches route paths against SkipPaths and SkipPathRegexps.
+// Results of globs and regexps are cached per path, so they're evaluated once per route.
+type pathSkipper struct {
+	exact   map[string]struct{}
+	globs   []string
+	regexps []*regexp.Regexp
+	cache   sync.Map
+	cached  atomic.Int64
+}
+
+func newPathSkipper(paths []string, regexps []*regexp.Regexp) *pathSkipper {
+	if len(paths) == 0 && len(regexps) == 0 {
+		return nil
+	}
+
+	skipper := &pathSkipper{
+		exact:   make(map[string]struct{}, len(paths)),
+		regexps: regexps,
+	}
+
+	for _, p := range paths {
+		if strings.ContainsAny(p, `*?[\`) {
+			skipper.globs = append(skipper.globs, p)
+			continue
+		}
+
+		skipper.exact[p] = struct{}{}
+	}
+
+	return skipper
+}
+
+func (s *pathSkipper) match(p string) bool {
+	if s == nil {
+		return false
+	}
+
+	if _, ok := s.exact[p]; ok {
+		return true
+	}
+
+	if len(s.globs) == 0 && len(s.regexps) == 0 {
+		return false
+	}
+
+	if cached, ok := s.cache.Load(p); ok {
+		matched, _ := cached.(bool)
+		return matched
+	}
+
+	matched := s.matchPatterns(p)
+	if s.cached.Load() < maxCachedPaths {
+		if _, loaded := s.cache.LoadOrStore(p, matched); !loaded {
+			s.cached.Add(1)
+		}
+	}
+
+	return matched
+}
+
+func (s *pathSkipper) matchPatterns(p string) bool {
+	for _, glob := range s.globs {
+		if ok, _ := path.Match(glob, p); ok {
+			return true
+		}
+	}
+
+	for _, rx := range s.regexps {
+		if rx.MatchString(p) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,55 @@
+package echozapmiddleware
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPathSkipper() *pathSkipper {
+	return newPathSkipper(
+		[]string{"/health", "/assets/*", "/static/*/*.js"},
+		[]*regexp.Regexp{regexp.MustCompile(`^/internal/.+/debug$`)},
+	)
+}
+
+func TestPathSkipper(t *testing.T) {
+	require.False(t, (*pathSkipper)(nil).match("/health"))
+
+	s := newTestPathSkipper()
+	for range 2 {
+		require.True(t, s.match("/health"))
+		require.True(t, s.match("/assets/:file"))
+		require.True(t, s.match("/internal/:id/debug"))
+		require.False(t, s.match("/api/users/:id"))
+	}
+
+	for i := range maxCachedPaths {
+		s.match("/api/" + strconv.Itoa(i))
+	}
+
+	require.False(t, s.match("/api/uncached"))
+	require.EqualValues(t, maxCachedPaths, s.cached.Load())
+}
+
+func BenchmarkPathSkipper(b *testing.B) {
+	s := newTestPathSkipper()
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			s.match("/api/users/:id")
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for range b.N {
+			s.matchPatterns("/api/users/:id")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package echozapmiddleware
+
+import (
+	"context"
+	"log/slog"
+
+	contextlogger "github.com/adlandh/context-logger"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MiddlewareWithSlog returns a middleware which logs through logger.
+// The entries have the same fields as the ones of Middleware.
+func MiddlewareWithSlog(logger *slog.Logger, config ...ZapConfig) echo.MiddlewareFunc {
+	return Middleware(zap.New(newSlogCore(logger.Handler())), config...)
+}
+
+// slogCore is a zapcore.Core which writes entries to a slog.Handler.
+type slogCore struct {
+	handler slog.Handler
+	ctx     context.Context //nolint:containedctx // the context of the fields added with With
+}
+
+func newSlogCore(handler slog.Handler) *slogCore {
+	return &slogCore{handler: handler, ctx: context.Background()}
+}
+
+func (c *slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(c.ctx, slogLevel(level))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	ctx, attrs := slogAttrs(c.ctx, fields)
+
+	return &slogCore{handler: c.handler.WithAttrs(attrs), ctx: ctx}
+}
+
+func (c *slogCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+
+	return checked
+}
+
+func (c *slogCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	ctx, attrs := slogAttrs(c.ctx, fields)
+
+	record := slog.NewRecord(entry.Time, slogLevel(entry.Level), entry.Message, 0)
+	record.AddAttrs(attrs...)
+
+	return c.handler.Handle(ctx, record) //nolint:wrapcheck // the error of the handler is returned as is
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+// slogAttrs converts fields to slog attributes.
+// The context added by contextlogger is returned instead of being converted.
+func slogAttrs(ctx context.Context, fields []zapcore.Field) (context.Context, []slog.Attr) {
+	attrs := make([]slog.Attr, 0, len(fields))
+
+	for _, field := range fields {
+		if field.Key == contextlogger.ContextKey {
+			if fieldCtx, ok := field.Interface.(context.Context); ok {
+				ctx = fieldCtx
+			}
+
+			continue
+		}
+
+		enc := zapcore.NewMapObjectEncoder()
+		field.AddTo(enc)
+
+		for key, value := range enc.Fields {
+			attrs = append(attrs, slog.Any(key, value))
+		}
+	}
+
+	return ctx, attrs
+}
+
+func slogLevel(level zapcore.Level) slog.Level {
+	switch {
+	case level <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case level == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case level == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
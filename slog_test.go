@@ -0,0 +1,35 @@
+package echozapmiddleware
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareWithSlog(t *testing.T) {
+	var buf bytes.Buffer
+
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	router := echo.New()
+	router.Use(MiddlewareWithSlog(logger, ZapConfig{AreHeadersDump: true, HeadersToLog: []string{"X-Test"}}))
+	router.GET("/ping", func(c echo.Context) error {
+		return c.String(http.StatusNotFound, "no pong")
+	})
+
+	r := httptest.NewRequest("GET", "/ping", nil)
+	r.Header.Set("X-Test", "value")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	require.Equal(t, http.StatusNotFound, w.Result().StatusCode)
+	require.Contains(t, buf.String(), `level=WARN msg="Client error" status=404`)
+	require.Contains(t, buf.String(), "method=GET uri=/ping")
+	require.Contains(t, buf.String(), "req.headers=map[X-Test:[value]]")
+	require.NotContains(t, buf.String(), "context-logger")
+}
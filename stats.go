@@ -0,0 +1,40 @@
+package echozapmiddleware
+
+import (
+	"expvar"
+	"strconv"
+)
+
+// Keys of the "echozap" expvar map.
+const (
+	statsRequests        = "requests"
+	statsLoggedBodyBytes = "logged_body_bytes"
+	statsTruncatedBodies = "truncated_bodies"
+	statsAsyncDropped    = "async_dropped"
+	statsArchiveErrors   = "archive_errors"
+)
+
+// stats are the request statistics published with expvar, e.g. on /debug/vars.
+var stats = expvar.NewMap("echozap")
+
+// statusClassKeys are the keys of the status class counters, so they aren't built per request.
+var statusClassKeys = [...]string{"status_0xx", "status_1xx", "status_2xx", "status_3xx", "status_4xx", "status_5xx"}
+
+// recordRequestStats counts a handled request and its status class, e.g. "status_2xx".
+func recordRequestStats(status int) {
+	stats.Add(statsRequests, 1)
+	if class := status / 100; class >= 0 && class < len(statusClassKeys) {
+		stats.Add(statusClassKeys[class], 1)
+	} else {
+		stats.Add("status_"+strconv.Itoa(class)+"xx", 1)
+	}
+}
+
+// recordBodyStats counts a logged body and whether it was truncated.
+func recordBodyStats(size int, truncated bool) {
+	stats.Add(statsLoggedBodyBytes, int64(size))
+
+	if truncated {
+		stats.Add(statsTruncatedBodies, 1)
+	}
+}
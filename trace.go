@@ -0,0 +1,204 @@
+package echozapmiddleware
+
+import (
+	"encoding/binary"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	spanEventName     = "http.access"
+	semconvStatusCode = "http.response.status_code"
+
+	headerTraceparent     = "Traceparent"
+	headerDatadogTraceID  = "X-Datadog-Trace-Id"
+	headerDatadogParentID = "X-Datadog-Parent-Id"
+)
+
+// addTrace returns ids of the span in the request context.
+// If there is no span, ids are taken from the W3C traceparent header.
+func addTrace(config ZapConfig, c echo.Context) []zapcore.Field {
+	sc, remote := requestSpanContext(c.Request())
+	if !sc.IsValid() {
+		return nil
+	}
+
+	spanIDName := config.FieldNames.SpanID
+	if remote {
+		spanIDName = config.FieldNames.ParentSpanID
+	}
+
+	return []zapcore.Field{
+		zap.String(config.FieldNames.TraceID, sc.TraceID().String()),
+		zap.String(spanIDName, sc.SpanID().String()),
+	}
+}
+
+// requestSpanContext returns the span context of the request context,
+// or the remote one parsed from the traceparent header if there is no span.
+func requestSpanContext(req *http.Request) (trace.SpanContext, bool) {
+	if sc := trace.SpanContextFromContext(req.Context()); sc.IsValid() {
+		return sc, false
+	}
+
+	return parseTraceparent(req.Header.Get(headerTraceparent)), true
+}
+
+// parseTraceparent parses a traceparent header value like "00-<trace-id>-<parent-id>-<flags>".
+// An invalid value results in an invalid span context.
+func parseTraceparent(value string) trace.SpanContext {
+	if value == "" {
+		return trace.SpanContext{}
+	}
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return trace.SpanContext{}
+	}
+
+	// version 00 has exactly four parts, later versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return trace.SpanContext{}
+	}
+
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return trace.SpanContext{}
+	}
+
+	parentID, err := trace.SpanIDFromHex(parts[2])
+	if err != nil {
+		return trace.SpanContext{}
+	}
+
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return trace.SpanContext{}
+	}
+
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     parentID,
+		TraceFlags: trace.TraceFlags(flags),
+		Remote:     true,
+	})
+}
+
+// addDatadogTrace returns ids of the span in the request context in the Datadog decimal format.
+// If there is no span, ids are taken from the Datadog or the W3C traceparent headers.
+func addDatadogTrace(config ZapConfig, c echo.Context) []zapcore.Field {
+	if !config.DatadogTrace {
+		return nil
+	}
+
+	traceID, spanID, ok := datadogIDs(c.Request())
+	if !ok {
+		return nil
+	}
+
+	return []zapcore.Field{
+		zap.String(config.FieldNames.DatadogTraceID, strconv.FormatUint(traceID, 10)),
+		zap.String(config.FieldNames.DatadogSpanID, strconv.FormatUint(spanID, 10)),
+	}
+}
+
+func datadogIDs(req *http.Request) (uint64, uint64, bool) {
+	if sc := trace.SpanContextFromContext(req.Context()); sc.IsValid() {
+		return datadogTraceID(sc.TraceID()), datadogSpanID(sc.SpanID()), true
+	}
+
+	if value := req.Header.Get(headerDatadogTraceID); value != "" {
+		traceID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || traceID == 0 {
+			return 0, 0, false
+		}
+
+		// the parent id is missing when the span is the root
+		spanID, _ := strconv.ParseUint(req.Header.Get(headerDatadogParentID), 10, 64)
+
+		return traceID, spanID, true
+	}
+
+	sc := parseTraceparent(req.Header.Get(headerTraceparent))
+	if !sc.IsValid() {
+		return 0, 0, false
+	}
+
+	return datadogTraceID(sc.TraceID()), datadogSpanID(sc.SpanID()), true
+}
+
+// datadogTraceID returns the lower 64 bits of id, which Datadog uses to correlate 128-bit trace ids.
+func datadogTraceID(id trace.TraceID) uint64 {
+	return binary.BigEndian.Uint64(id[8:])
+}
+
+func datadogSpanID(id trace.SpanID) uint64 {
+	return binary.BigEndian.Uint64(id[:])
+}
+
+// recordSpanEvent adds the summarized request to the active span as an event.
+func recordSpanEvent(config ZapConfig, c echo.Context, latency time.Duration) {
+	if !config.RecordSpanEvent {
+		return
+	}
+
+	span := trace.SpanFromContext(c.Request().Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	req := c.Request()
+	res := c.Response()
+	names := config.FieldNames
+
+	attributes := []attribute.KeyValue{
+		attribute.Int(names.Status, res.Status),
+		attribute.String(names.Latency, latency.String()),
+		attribute.String(names.Method, req.Method),
+	}
+
+	if names.URLQuery == "" {
+		attributes = append(attributes, attribute.String(names.URI, requestURI(config, c)))
+	} else {
+		path, query := requestPathAndQuery(config, c)
+		attributes = append(attributes, attribute.String(names.URI, path), attribute.String(names.URLQuery, query))
+	}
+
+	span.AddEvent(spanEventName, trace.WithAttributes(append(attributes,
+		attribute.Int64(names.BytesIn, max(req.ContentLength, 0)),
+		attribute.Int64(names.BytesOut, res.Size),
+	)...))
+}
+
+// markSpanError sets the status of the active span to Error if the handler failed or the status is 5xx.
+func markSpanError(config ZapConfig, c echo.Context, err error) {
+	status := c.Response().Status
+	if !config.MarkSpanErrors || (err == nil && status < http.StatusInternalServerError) {
+		return
+	}
+
+	span := trace.SpanFromContext(c.Request().Context())
+	if !span.IsRecording() {
+		return
+	}
+
+	span.SetAttributes(attribute.Int(semconvStatusCode, status))
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		return
+	}
+
+	span.SetStatus(codes.Error, http.StatusText(status))
+}
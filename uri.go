@@ -0,0 +1,145 @@
+package echozapmiddleware
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// appendURI appends the uri of the request, or its path and query if FieldNames.URLQuery is set.
+func appendURI(fields []zapcore.Field, config ZapConfig, c echo.Context) []zapcore.Field {
+	names := config.FieldNames
+	if names.URLQuery == "" {
+		return append(fields, zap.String(names.URI, requestURI(config, c)))
+	}
+
+	path, query := requestPathAndQuery(config, c)
+	fields = append(fields, zap.String(names.URI, path))
+
+	return appendNonEmpty(fields, names.URLQuery, query)
+}
+
+// requestPathAndQuery returns the unescaped path and the raw query of the request to be logged,
+// with the values of RedactParams and RedactQueryParams redacted.
+func requestPathAndQuery(config ZapConfig, c echo.Context) (string, string) {
+	u := c.Request().URL
+	path, _ := redactPath(config, c, u.Path)
+	query, _ := redactQuery(u.RawQuery, config.RedactQueryParams)
+
+	return path, query
+}
+
+// requestURI returns the uri of the request to be logged, with the values of RedactParams and RedactQueryParams
+// redacted, so the secrets of the path and the query don't leak through the uri when they are redacted.
+func requestURI(config ZapConfig, c echo.Context) string {
+	uri := c.Request().RequestURI
+
+	path, query, hasQuery := strings.Cut(uri, "?")
+	path, pathRedacted := redactPath(config, c, path)
+	query, queryRedacted := redactQuery(query, config.RedactQueryParams)
+
+	switch {
+	case !pathRedacted && !queryRedacted:
+		return uri
+	case !hasQuery:
+		return path
+	default:
+		return path + "?" + query
+	}
+}
+
+// redactPath returns path with the segments of the path parameters of RedactParams redacted
+// and whether any of them was redacted. The segments are found by the route of the request.
+// If they can't be, the route is returned instead of path.
+func redactPath(config ZapConfig, c echo.Context, path string) (string, bool) {
+	redact := config.ExtraFields.RedactParams
+	route := c.Path()
+
+	if len(redact) == 0 || !strings.ContainsAny(route, ":*") {
+		return path, false
+	}
+
+	routeSegments := strings.Split(route, "/")
+	segments := strings.Split(path, "/")
+	redacted := false
+
+	for i, part := range routeSegments {
+		if i >= len(segments) {
+			return route, true
+		}
+
+		// the wildcard matches the rest of the path
+		if prefix, ok := strings.CutSuffix(part, "*"); ok {
+			if !slices.Contains(redact, "*") {
+				return strings.Join(segments, "/"), redacted
+			}
+
+			return strings.Join(append(segments[:i], prefix+redactedValue), "/"), true
+		}
+
+		if name, ok := strings.CutPrefix(part, ":"); ok && slices.Contains(redact, name) {
+			segments[i] = redactedValue
+			redacted = true
+		}
+	}
+
+	if len(segments) != len(routeSegments) {
+		return route, true
+	}
+
+	return strings.Join(segments, "/"), redacted
+}
+
+// redactQuery returns rawQuery with the values of the sensitive parameters redacted
+// and whether any of them was redacted. The order and the encoding of the other pairs are kept.
+func redactQuery(rawQuery string, sensitive []string) (string, bool) {
+	if !hasSensitiveParam(rawQuery, sensitive) {
+		return rawQuery, false
+	}
+
+	var b strings.Builder
+
+	b.Grow(len(rawQuery))
+
+	for rest := rawQuery; rest != ""; {
+		pair, next, more := strings.Cut(rest, "&")
+
+		if name, _, hasValue := strings.Cut(pair, "="); hasValue && isSensitiveParam(name, sensitive) {
+			b.WriteString(name)
+			b.WriteByte('=')
+			b.WriteString(redactedValue)
+		} else {
+			b.WriteString(pair)
+		}
+
+		if more {
+			b.WriteByte('&')
+		}
+
+		rest = next
+	}
+
+	return b.String(), true
+}
+
+// hasSensitiveParam reports whether rawQuery has a value of a sensitive parameter.
+func hasSensitiveParam(rawQuery string, sensitive []string) bool {
+	if len(sensitive) == 0 {
+		return false
+	}
+
+	for rest := rawQuery; rest != ""; {
+		var pair string
+
+		pair, rest, _ = strings.Cut(rest, "&")
+
+		if name, _, hasValue := strings.Cut(pair, "="); hasValue && isSensitiveParam(name, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
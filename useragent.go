@@ -0,0 +1,39 @@
+package echozapmiddleware
+
+import (
+	"net/http"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// UserAgent describes a parsed User-Agent header.
+type UserAgent struct {
+	Browser string
+	OS      string
+	Device  string
+	Bot     bool
+}
+
+// UAParser parses User-Agent headers, e.g. by wrapping a user agent parsing library.
+type UAParser interface {
+	Parse(userAgent string) UserAgent
+}
+
+// addUserAgent returns the fields of the parsed User-Agent header. Empty values are omitted.
+func addUserAgent(config ZapConfig, req *http.Request) []zapcore.Field {
+	if config.UAParser == nil || req.UserAgent() == "" {
+		return nil
+	}
+
+	ua := config.UAParser.Parse(req.UserAgent())
+	names := config.FieldNames
+
+	var fields []zapcore.Field
+
+	fields = appendNonEmpty(fields, names.UABrowser, ua.Browser)
+	fields = appendNonEmpty(fields, names.UAOS, ua.OS)
+	fields = appendNonEmpty(fields, names.UADevice, ua.Device)
+
+	return append(fields, zap.Bool(names.UABot, ua.Bot))
+}
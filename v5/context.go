@@ -0,0 +1,298 @@
+package echozapmiddleware
+
+import (
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+
+	echov4 "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v5"
+)
+
+// v4Context presents an Echo v5 context as the Echo v4 one the middleware and the ZapConfig functions work with.
+// Responses are written through an Echo v4 response, which records their status and size.
+type v4Context struct {
+	ctx      *echo.Context
+	echo     *echov4.Echo
+	response *echov4.Response
+	handler  echov4.HandlerFunc
+	logger   echov4.Logger
+}
+
+var _ echov4.Context = (*v4Context)(nil)
+
+func newV4Context(c *echo.Context, e *echov4.Echo) *v4Context {
+	response := echov4.NewResponse(c.Response(), e)
+	c.SetResponse(response)
+
+	return &v4Context{ctx: c, echo: e, response: response, logger: e.Logger}
+}
+
+// Context returns the Echo v5 context of the request, e.g. in the functions of ZapConfig.
+// It returns nil if c isn't passed by this package.
+func Context(c echov4.Context) *echo.Context {
+	if c, ok := c.(*v4Context); ok {
+		return c.ctx
+	}
+
+	return nil
+}
+
+func (c *v4Context) Request() *http.Request {
+	return c.ctx.Request()
+}
+
+func (c *v4Context) SetRequest(r *http.Request) {
+	c.ctx.SetRequest(r)
+}
+
+func (c *v4Context) SetResponse(r *echov4.Response) {
+	c.response = r
+	c.ctx.SetResponse(r)
+}
+
+func (c *v4Context) Response() *echov4.Response {
+	return c.response
+}
+
+func (c *v4Context) IsTLS() bool {
+	return c.ctx.IsTLS()
+}
+
+func (c *v4Context) IsWebSocket() bool {
+	return c.ctx.IsWebSocket()
+}
+
+func (c *v4Context) Scheme() string {
+	return c.ctx.Scheme()
+}
+
+func (c *v4Context) RealIP() string {
+	return c.ctx.RealIP()
+}
+
+func (c *v4Context) Path() string {
+	return c.ctx.Path()
+}
+
+func (c *v4Context) SetPath(p string) {
+	c.ctx.SetPath(p)
+}
+
+func (c *v4Context) Param(name string) string {
+	return c.ctx.Param(name)
+}
+
+func (c *v4Context) ParamNames() []string {
+	values := c.ctx.PathValues()
+	names := make([]string, len(values))
+
+	for i, value := range values {
+		names[i] = value.Name
+	}
+
+	return names
+}
+
+func (c *v4Context) SetParamNames(names ...string) {
+	values := make(echo.PathValues, len(names))
+	for i, name := range names {
+		values[i] = echo.PathValue{Name: name, Value: c.ctx.Param(name)}
+	}
+
+	c.ctx.SetPathValues(values)
+}
+
+func (c *v4Context) ParamValues() []string {
+	values := c.ctx.PathValues()
+	result := make([]string, len(values))
+
+	for i, value := range values {
+		result[i] = value.Value
+	}
+
+	return result
+}
+
+func (c *v4Context) SetParamValues(values ...string) {
+	pathValues := c.ctx.PathValues()
+	for i := range pathValues {
+		if i < len(values) {
+			pathValues[i].Value = values[i]
+		}
+	}
+
+	c.ctx.SetPathValues(pathValues)
+}
+
+func (c *v4Context) QueryParam(name string) string {
+	return c.ctx.QueryParam(name)
+}
+
+func (c *v4Context) QueryParams() url.Values {
+	return c.ctx.QueryParams()
+}
+
+func (c *v4Context) QueryString() string {
+	return c.ctx.QueryString()
+}
+
+func (c *v4Context) FormValue(name string) string {
+	return c.ctx.FormValue(name)
+}
+
+func (c *v4Context) FormParams() (url.Values, error) {
+	return c.ctx.FormValues() //nolint:wrapcheck
+}
+
+func (c *v4Context) FormFile(name string) (*multipart.FileHeader, error) {
+	return c.ctx.FormFile(name) //nolint:wrapcheck
+}
+
+func (c *v4Context) MultipartForm() (*multipart.Form, error) {
+	return c.ctx.MultipartForm() //nolint:wrapcheck
+}
+
+func (c *v4Context) Cookie(name string) (*http.Cookie, error) {
+	return c.ctx.Cookie(name) //nolint:wrapcheck
+}
+
+func (c *v4Context) SetCookie(cookie *http.Cookie) {
+	c.ctx.SetCookie(cookie)
+}
+
+func (c *v4Context) Cookies() []*http.Cookie {
+	return c.ctx.Cookies()
+}
+
+func (c *v4Context) Get(key string) any {
+	return c.ctx.Get(key)
+}
+
+func (c *v4Context) Set(key string, val any) {
+	c.ctx.Set(key, val)
+}
+
+func (c *v4Context) Bind(i any) error {
+	return c.ctx.Bind(i) //nolint:wrapcheck
+}
+
+func (c *v4Context) Validate(i any) error {
+	return c.ctx.Validate(i) //nolint:wrapcheck
+}
+
+func (c *v4Context) Render(code int, name string, data any) error {
+	return c.ctx.Render(code, name, data) //nolint:wrapcheck
+}
+
+func (c *v4Context) HTML(code int, html string) error {
+	return c.ctx.HTML(code, html) //nolint:wrapcheck
+}
+
+func (c *v4Context) HTMLBlob(code int, b []byte) error {
+	return c.ctx.HTMLBlob(code, b) //nolint:wrapcheck
+}
+
+func (c *v4Context) String(code int, s string) error {
+	return c.ctx.String(code, s) //nolint:wrapcheck
+}
+
+func (c *v4Context) JSON(code int, i any) error {
+	return c.ctx.JSON(code, i) //nolint:wrapcheck
+}
+
+func (c *v4Context) JSONPretty(code int, i any, indent string) error {
+	return c.ctx.JSONPretty(code, i, indent) //nolint:wrapcheck
+}
+
+func (c *v4Context) JSONBlob(code int, b []byte) error {
+	return c.ctx.JSONBlob(code, b) //nolint:wrapcheck
+}
+
+func (c *v4Context) JSONP(code int, callback string, i any) error {
+	return c.ctx.JSONP(code, callback, i) //nolint:wrapcheck
+}
+
+func (c *v4Context) JSONPBlob(code int, callback string, b []byte) error {
+	return c.ctx.JSONPBlob(code, callback, b) //nolint:wrapcheck
+}
+
+func (c *v4Context) XML(code int, i any) error {
+	return c.ctx.XML(code, i) //nolint:wrapcheck
+}
+
+func (c *v4Context) XMLPretty(code int, i any, indent string) error {
+	return c.ctx.XMLPretty(code, i, indent) //nolint:wrapcheck
+}
+
+func (c *v4Context) XMLBlob(code int, b []byte) error {
+	return c.ctx.XMLBlob(code, b) //nolint:wrapcheck
+}
+
+func (c *v4Context) Blob(code int, contentType string, b []byte) error {
+	return c.ctx.Blob(code, contentType, b) //nolint:wrapcheck
+}
+
+func (c *v4Context) Stream(code int, contentType string, r io.Reader) error {
+	return c.ctx.Stream(code, contentType, r) //nolint:wrapcheck
+}
+
+func (c *v4Context) File(file string) error {
+	return c.ctx.File(file) //nolint:wrapcheck
+}
+
+func (c *v4Context) Attachment(file string, name string) error {
+	return c.ctx.Attachment(file, name) //nolint:wrapcheck
+}
+
+func (c *v4Context) Inline(file string, name string) error {
+	return c.ctx.Inline(file, name) //nolint:wrapcheck
+}
+
+func (c *v4Context) NoContent(code int) error {
+	return c.ctx.NoContent(code) //nolint:wrapcheck
+}
+
+func (c *v4Context) Redirect(code int, url string) error {
+	return c.ctx.Redirect(code, url) //nolint:wrapcheck
+}
+
+// Error responds with err using the error handler of the Echo v5 instance.
+func (c *v4Context) Error(err error) {
+	handler := echo.DefaultHTTPErrorHandler(false)
+	if e := c.ctx.Echo(); e != nil && e.HTTPErrorHandler != nil {
+		handler = e.HTTPErrorHandler
+	}
+
+	handler(c.ctx, err)
+}
+
+func (c *v4Context) Handler() echov4.HandlerFunc {
+	return c.handler
+}
+
+func (c *v4Context) SetHandler(h echov4.HandlerFunc) {
+	c.handler = h
+}
+
+func (c *v4Context) Logger() echov4.Logger {
+	return c.logger
+}
+
+func (c *v4Context) SetLogger(l echov4.Logger) {
+	c.logger = l
+}
+
+// Echo returns the Echo v4 instance the responses are written with. It doesn't serve requests.
+func (c *v4Context) Echo() *echov4.Echo {
+	return c.echo
+}
+
+func (c *v4Context) Reset(r *http.Request, w http.ResponseWriter) {
+	c.ctx.Reset(r, w)
+	c.response = echov4.NewResponse(c.ctx.Response(), c.echo)
+	c.ctx.SetResponse(c.response)
+	c.handler = nil
+	c.logger = c.echo.Logger
+}
@@ -0,0 +1,35 @@
+module github.com/adlandh/echo-zap-middleware/v5
+
+go 1.25.0
+
+require (
+	github.com/adlandh/context-logger v1.3.3
+	github.com/adlandh/echo-zap-middleware v0.0.0-00010101000000-000000000000
+	github.com/labstack/echo/v4 v4.13.3
+	github.com/labstack/echo/v5 v5.3.1
+	github.com/stretchr/testify v1.11.1
+	go.uber.org/zap v1.27.0
+)
+
+require (
+	github.com/andybalholm/brotli v1.1.1 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/labstack/gommon v0.4.2 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	go.opentelemetry.io/otel v1.28.0 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	go.uber.org/multierr v1.11.0 // indirect
+	golang.org/x/crypto v0.54.0 // indirect
+	golang.org/x/net v0.57.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+	golang.org/x/text v0.40.0 // indirect
+	golang.org/x/time v0.15.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// the module is developed along with the Echo v4 middleware it runs
+replace github.com/adlandh/echo-zap-middleware => ../
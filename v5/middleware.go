@@ -0,0 +1,80 @@
+// Package echozapmiddleware provides the Zap Logger middleware for Echo v5.
+// It runs the middleware of github.com/adlandh/echo-zap-middleware with the same ZapConfig.
+// The functions of ZapConfig get the request as an Echo v4 context, Context returns its Echo v5 one.
+package echozapmiddleware
+
+import (
+	contextlogger "github.com/adlandh/context-logger"
+	echozap "github.com/adlandh/echo-zap-middleware"
+	echov4 "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v5"
+	"go.uber.org/zap"
+)
+
+type (
+	// ZapConfig is the config of the middleware, see echozap.ZapConfig.
+	ZapConfig = echozap.ZapConfig
+	// ConfigHolder holds a config which can be replaced at runtime, see echozap.ConfigHolder.
+	ConfigHolder = echozap.ConfigHolder
+)
+
+// DefaultZapConfig is the default config of the middleware.
+var DefaultZapConfig = echozap.DefaultZapConfig
+
+// NewConfigHolder returns a ConfigHolder with config, see echozap.NewConfigHolder.
+func NewConfigHolder(config ...ZapConfig) *ConfigHolder {
+	return echozap.NewConfigHolder(config...)
+}
+
+// Middleware returns a Zap Logger middleware with config.
+// If config is not passed, DefaultZapConfig will be used.
+func Middleware(logger *zap.Logger, config ...ZapConfig) echo.MiddlewareFunc {
+	return adapt(echozap.Middleware(logger, config...))
+}
+
+// MiddlewareWithConfigHolder returns a Zap Logger middleware, which reads its config from holder on every request.
+func MiddlewareWithConfigHolder(logger *zap.Logger, holder *ConfigHolder) echo.MiddlewareFunc {
+	return adapt(echozap.MiddlewareWithConfigHolder(logger, holder))
+}
+
+// MiddlewareWithContextLogger returns a Zap Logger middleware with context logger and config.
+// If config is not passed, DefaultZapConfig will be used.
+func MiddlewareWithContextLogger(ctxLogger *contextlogger.ContextLogger, config ...ZapConfig) echo.MiddlewareFunc {
+	return adapt(echozap.MiddlewareWithContextLogger(ctxLogger, config...))
+}
+
+// MiddlewareWithContextLoggerAndConfigHolder returns a Zap Logger middleware with context logger,
+// which reads its config from holder on every request.
+func MiddlewareWithContextLoggerAndConfigHolder(
+	ctxLogger *contextlogger.ContextLogger,
+	holder *ConfigHolder,
+) echo.MiddlewareFunc {
+	return adapt(echozap.MiddlewareWithContextLoggerAndConfigHolder(ctxLogger, holder))
+}
+
+// Logger returns the request-scoped logger stored by the middleware with InjectLogger enabled.
+// If there is none, the global zap logger is returned.
+func Logger(c *echo.Context) *zap.Logger {
+	if logger, ok := c.Get(echozap.LoggerContextKey).(*zap.Logger); ok {
+		return logger
+	}
+
+	return zap.L()
+}
+
+// adapt runs the Echo v4 middleware mw in an Echo v5 middleware.
+func adapt(mw echov4.MiddlewareFunc) echo.MiddlewareFunc {
+	// the Echo v4 instance only serves as the logger of the Echo v4 responses
+	e := echov4.New()
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			response := c.Response()
+			defer c.SetResponse(response)
+
+			return mw(func(echov4.Context) error {
+				return next(c)
+			})(newV4Context(c, e))
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package echozapmiddleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	echov4 "github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v5"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newRouter(t *testing.T, config ...ZapConfig) (*echo.Echo, *observer.ObservedLogs) {
+	t.Helper()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	router := echo.New()
+	router.Use(Middleware(zap.New(core), config...))
+
+	return router, logs
+}
+
+func serve(router *echo.Echo, method, target string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	router, logs := newRouter(t)
+	router.GET("/users/:id", func(c *echo.Context) error {
+		return c.String(http.StatusCreated, "user "+c.Param("id"))
+	})
+
+	w := serve(router, http.MethodGet, "/users/1?x=1", "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "user 1", w.Body.String())
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	require.Equal(t, "Success", entry.Message)
+
+	fields := entry.ContextMap()
+	require.EqualValues(t, http.StatusCreated, fields["status"])
+	require.Equal(t, "GET", fields["method"])
+	require.Equal(t, "/users/1?x=1", fields["uri"])
+	require.EqualValues(t, len("user 1"), fields["bytes_out"])
+}
+
+func TestMiddlewareWithErrors(t *testing.T) {
+	router, logs := newRouter(t)
+	router.GET("/fail", func(*echo.Context) error {
+		return echo.NewHTTPError(http.StatusBadGateway, "upstream failed")
+	})
+
+	w := serve(router, http.MethodGet, "/fail", "")
+	require.Equal(t, http.StatusBadGateway, w.Code)
+
+	w = serve(router, http.MethodGet, "/missing", "")
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	require.Equal(t, "Server error", entries[0].Message)
+	require.EqualValues(t, http.StatusBadGateway, entries[0].ContextMap()["status"])
+	require.EqualValues(t, http.StatusBadGateway, entries[0].ContextMap()["error.code"])
+	require.Equal(t, "Client error", entries[1].Message)
+	require.EqualValues(t, http.StatusNotFound, entries[1].ContextMap()["status"])
+}
+
+func TestMiddlewareWithBodyDump(t *testing.T) {
+	router, logs := newRouter(t, ZapConfig{IsBodyDump: true})
+	router.POST("/echo", func(c *echo.Context) error {
+		var body map[string]string
+		if err := c.Bind(&body); err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, body)
+	})
+
+	r := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(`{"name":"john"}`))
+	r.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	fields := logs.All()[0].ContextMap()
+	require.Equal(t, `{"name":"john"}`, fields["req.body"])
+	require.Equal(t, "{\"name\":\"john\"}\n", fields["resp.body"])
+}
+
+func TestMiddlewareWithConfigFuncs(t *testing.T) {
+	var paths []string
+
+	router, logs := newRouter(t, ZapConfig{
+		InjectLogger: true,
+		Skipper: func(c echov4.Context) bool {
+			paths = append(paths, Context(c).Path())
+			return c.Request().URL.Path == "/skip"
+		},
+	})
+	router.GET("/ping", func(c *echo.Context) error {
+		Logger(c).Info("handler")
+		return c.NoContent(http.StatusOK)
+	})
+	router.GET("/skip", func(c *echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	serve(router, http.MethodGet, "/ping", "")
+	serve(router, http.MethodGet, "/skip", "")
+
+	require.Equal(t, []string{"/ping", "/skip"}, paths)
+	require.Equal(t, 2, logs.Len())
+	require.Equal(t, "handler", logs.All()[0].Message)
+	require.Equal(t, "/ping", logs.All()[0].ContextMap()["uri"])
+	require.Equal(t, "Success", logs.All()[1].Message)
+}
@@ -0,0 +1,167 @@
+package echozapmiddleware
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+)
+
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// processXML redacts character data of the given elements and applies format to XML body.
+// Bodies which can't be parsed are excluded when elements are redacted and returned as is otherwise.
+func processXML(elements []string, format BodyFormat, body string) string {
+	if len(elements) == 0 && format == BodyFormatAsIs {
+		return body
+	}
+
+	result, err := rewriteXML(elements, format, body)
+	if err != nil {
+		if len(elements) > 0 {
+			return excludedValue
+		}
+
+		return body
+	}
+
+	return result
+}
+
+func rewriteXML(elements []string, format BodyFormat, body string) (string, error) {
+	w := &xmlWriter{format: format}
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	redactDepth := 0
+
+	for {
+		token, err := decoder.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return "", err //nolint:wrapcheck
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if redactDepth > 0 {
+				redactDepth++
+				continue
+			}
+
+			if slices.Contains(elements, t.Name.Local) {
+				redactDepth = 1
+			}
+
+			w.start(t, redactDepth == 1)
+		case xml.EndElement:
+			if redactDepth > 1 {
+				redactDepth--
+				continue
+			}
+
+			redactDepth = 0
+
+			w.end(t)
+		default:
+			if redactDepth == 0 {
+				w.write(t)
+			}
+		}
+	}
+
+	return w.buf.String(), nil
+}
+
+// xmlWriter writes raw XML tokens keeping namespace prefixes as they are.
+type xmlWriter struct {
+	buf    bytes.Buffer
+	format BodyFormat
+	depth  int
+	inText bool
+	opened bool
+}
+
+func (w *xmlWriter) indent() {
+	if w.format != BodyFormatPretty || w.inText {
+		return
+	}
+
+	if w.buf.Len() > 0 {
+		w.buf.WriteByte('\n')
+	}
+
+	w.buf.WriteString(strings.Repeat("  ", w.depth))
+}
+
+func (w *xmlWriter) start(t xml.StartElement, redacted bool) {
+	w.indent()
+	w.buf.WriteString("<" + xmlName(t.Name))
+
+	for _, attr := range t.Attr {
+		w.buf.WriteString(" " + xmlName(attr.Name) + `="`)
+		_ = xml.EscapeText(&w.buf, []byte(attr.Value))
+		w.buf.WriteString(`"`)
+	}
+
+	w.buf.WriteString(">")
+	w.depth++
+	w.inText = false
+	w.opened = true
+
+	if redacted {
+		w.buf.WriteString(redactedValue)
+		w.inText = true
+	}
+}
+
+func (w *xmlWriter) end(t xml.EndElement) {
+	w.depth--
+
+	// keep empty elements on a single line
+	if !w.opened {
+		w.indent()
+	}
+
+	w.buf.WriteString("</" + xmlName(t.Name) + ">")
+	w.inText = false
+	w.opened = false
+}
+
+func (w *xmlWriter) write(token xml.Token) {
+	if t, ok := token.(xml.CharData); ok {
+		if w.format != BodyFormatAsIs && len(bytes.TrimSpace(t)) == 0 {
+			return
+		}
+
+		_ = xml.EscapeText(&w.buf, t)
+		w.inText = true
+
+		return
+	}
+
+	w.indent()
+	w.opened = false
+
+	switch t := token.(type) {
+	case xml.ProcInst:
+		w.buf.WriteString("<?" + t.Target + " " + string(t.Inst) + "?>")
+	case xml.Comment:
+		w.buf.WriteString("<!--" + string(t) + "-->")
+	case xml.Directive:
+		w.buf.WriteString("<!" + string(t) + ">")
+	}
+}
+
+func xmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}